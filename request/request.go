@@ -6,8 +6,11 @@ A set of functions to retrieve information about the incoming requests made by c
 package request
 
 import (
+	"bytes"
 	"errors"
+	"io"
 	"io/ioutil" //nolint:all // TODO: update to remove deprecated dependency
+	"os"
 
 	"github.com/Kong/go-pdk/bridge"
 	"github.com/Kong/go-pdk/server/kong_plugin_protocol"
@@ -228,6 +231,33 @@ func (r Request) GetRawBody() ([]byte, error) {
 	}
 }
 
+// kong.Request.GetRawBodyReader() returns the plain request body as a stream.
+//
+// If Nginx has buffered the body to a file (because it's larger than
+// client_body_buffer_size), the file is read as the stream is consumed,
+// instead of loading all of it in memory.  The caller must close the stream.
+func (r Request) GetRawBodyReader() (io.ReadCloser, error) {
+	out := new(kong_plugin_protocol.RawBodyResult)
+	err := r.Ask(`kong.request.get_raw_body`, nil, out)
+	if err != nil {
+		return nil, err
+	}
+
+	switch x := out.Kind.(type) {
+	case *kong_plugin_protocol.RawBodyResult_Content:
+		return io.NopCloser(bytes.NewReader(x.Content)), nil
+
+	case *kong_plugin_protocol.RawBodyResult_BodyFilepath:
+		return os.Open(x.BodyFilepath)
+
+	case *kong_plugin_protocol.RawBodyResult_Error:
+		return nil, errors.New(x.Error)
+
+	default:
+		return io.NopCloser(bytes.NewReader(out.GetContent())), nil
+	}
+}
+
 // kong.Request.GetUriCaptures() returns the catured URI fragements.
 //
 //
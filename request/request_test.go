@@ -1,6 +1,10 @@
 package request
 
 import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/Kong/go-pdk/bridge"
@@ -122,3 +126,44 @@ this is the content`
 	assert.NoError(t, err)
 	assert.Equal(t, []byte(body), ret_b)
 }
+
+func TestGetRawBodyReader(t *testing.T) {
+	const chunkSize = 64 * 1024
+	body := bytes.Repeat([]byte("0123456789abcdef"), 16*chunkSize/16)
+	bodyFile := filepath.Join(t.TempDir(), "body")
+	assert.NoError(t, os.WriteFile(bodyFile, body, 0o600))
+
+	request := mockRequest(t, []bridgetest.MockStep{
+		{"kong.request.get_raw_body", nil, &kong_plugin_protocol.RawBodyResult{
+			Kind: &kong_plugin_protocol.RawBodyResult_BodyFilepath{BodyFilepath: bodyFile},
+		}},
+		{"kong.request.get_raw_body", nil, bridge.WrapString("small body")},
+	})
+
+	r, err := request.GetRawBodyReader()
+	assert.NoError(t, err)
+
+	read := []byte{}
+	chunks := 0
+	chunk := make([]byte, chunkSize)
+	for {
+		n, err := r.Read(chunk)
+		if n > 0 {
+			chunks++
+			read = append(read, chunk[:n]...)
+		}
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, r.Close())
+	assert.Equal(t, 16, chunks)
+	assert.Equal(t, body, read)
+
+	r, err = request.GetRawBodyReader()
+	assert.NoError(t, err)
+	small, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("small body"), small)
+}
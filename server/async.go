@@ -0,0 +1,73 @@
+package server
+
+import (
+	"fmt"
+	"log"
+)
+
+// A PendingPolicy tells what to do with the events of an instance whose
+// Configure hook is still running, when it's run asynchronously.
+type PendingPolicy int
+
+const (
+	// WaitPending holds events until the instance is configured.
+	WaitPending PendingPolicy = iota
+	// SkipPending lets requests through without calling the handler.
+	SkipPending
+	// RejectPending fails requests.
+	RejectPending
+)
+
+// configureAsync runs the Configure hook of an instance started by
+// StartInstance with WithAsyncConfigure.  If it fails, the instance is
+// closed.
+func (rh *rpcHandler) configureAsync(instance *instanceData) {
+	err := configure(instance.config)
+	if err != nil {
+		log.Printf("instance %d: %s", instance.id, err)
+		rh.lock.Lock()
+		if rh.instances[instance.id] == instance {
+			delete(rh.instances, instance.id)
+		}
+		rh.lock.Unlock()
+	}
+
+	instance.configErr = err
+	close(instance.ready)
+}
+
+// pending tells whether the instance is still being configured.
+func (instance *instanceData) pending() bool {
+	if instance.ready == nil {
+		return false
+	}
+
+	select {
+	case <-instance.ready:
+		return false
+	default:
+		return true
+	}
+}
+
+// awaitReady applies the policy given to WithAsyncConfigure to an event of
+// the instance.  Returns whether to run its handler.
+func (rh *rpcHandler) awaitReady(instance *instanceData) (bool, error) {
+	if instance.pending() {
+		switch rh.pendingPolicy {
+		case SkipPending:
+			return false, nil
+		case RejectPending:
+			return false, fmt.Errorf("instance %d is still being configured", instance.id)
+		}
+	}
+
+	if instance.ready != nil {
+		<-instance.ready
+		if instance.configErr != nil {
+			return false, fmt.Errorf("instance %d failed: %w", instance.id, instance.configErr)
+		}
+	}
+
+	return true, nil
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Kong/go-pdk"
+	"github.com/stretchr/testify/assert"
+)
+
+type asyncConfig struct {
+	Fail    bool `json:"fail"`
+	release chan struct{}
+	calls   *atomic.Int32
+}
+
+func (c *asyncConfig) Configure() error {
+	<-c.release
+	if c.Fail {
+		return errors.New("can't configure")
+	}
+	return nil
+}
+
+func (c *asyncConfig) Access(kong *pdk.PDK) {
+	c.calls.Add(1)
+}
+
+func TestAsyncConfigure(t *testing.T) {
+	start := func(policy PendingPolicy, config string) (*rpcHandler, InstanceStatus, chan struct{}, *atomic.Int32) {
+		release := make(chan struct{})
+		calls := &atomic.Int32{}
+		rh := newRpcHandler(func() interface{} { return &asyncConfig{release: release, calls: calls} }, "0.1", 1,
+			WithAsyncConfigure(policy))
+
+		var status InstanceStatus
+		assert.NoError(t, rh.StartInstance(PluginConfig{Name: "test", Config: []byte(config)}, &status))
+		assert.True(t, status.Pending)
+		return rh, status, release, calls
+	}
+
+	t.Run("skip", func(t *testing.T) {
+		rh, status, release, calls := start(SkipPending, `{"__seq__":1}`)
+		assert.NoError(t, dispatch(t, rh, status.Id, "access"))
+		assert.Equal(t, int32(0), calls.Load())
+
+		close(release)
+		<-rh.instances[status.Id].ready
+		assert.NoError(t, rh.InstanceStatus(status.Id, &status))
+		assert.False(t, status.Pending)
+		assert.NoError(t, dispatch(t, rh, status.Id, "access"))
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		rh, status, release, calls := start(RejectPending, `{"__seq__":1}`)
+		assert.EqualError(t, dispatch(t, rh, status.Id, "access"), "instance 1 is still being configured")
+		assert.Equal(t, int32(0), calls.Load())
+		close(release)
+	})
+
+	t.Run("wait", func(t *testing.T) {
+		rh, status, release, calls := start(WaitPending, `{"__seq__":1}`)
+		done := make(chan error)
+		go func() { done <- dispatch(t, rh, status.Id, "access") }()
+
+		select {
+		case <-done:
+			t.Fatal("event handled before the instance was ready")
+		case <-time.After(20 * time.Millisecond):
+		}
+		close(release)
+		assert.NoError(t, <-done)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		rh, status, release, calls := start(WaitPending, `{"__seq__":1,"fail":true}`)
+		instance := rh.instances[status.Id]
+		done := make(chan error)
+		go func() { done <- dispatch(t, rh, status.Id, "access") }()
+
+		time.Sleep(10 * time.Millisecond)
+		close(release)
+		<-instance.ready
+		assert.EqualError(t, <-done, "instance 1 failed: configuring instance: can't configure")
+		assert.Equal(t, int32(0), calls.Load())
+		assert.Error(t, rh.InstanceStatus(status.Id, &status))
+	})
+}
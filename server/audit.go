@@ -0,0 +1,149 @@
+package server
+
+import "reflect"
+
+// Replaces the values of secret fields in a ConfigChange.
+const redacted = "[redacted]"
+
+// A ConfigChange describes a new configuration of a plugin, replacing the
+// one of a previous instance with the same key.
+type ConfigChange struct {
+	Key    string        // plugin key, as sent by Kong
+	OldSeq int           // sequence number of the previous instance
+	NewSeq int           // sequence number of the new instance
+	Fields []FieldChange // changed fields, in declaration order
+}
+
+// A FieldChange is a config field holding a different value.  Values of
+// secret fields (tagged with `kong:"encrypted=true"` or
+// `kong:"referenceable=true"`) are replaced by "[redacted]".
+type FieldChange struct {
+	Path     string      // dot-separated field names, as in the config data
+	Old, New interface{} // the values
+}
+
+// auditChange reports to the WithConfigAudit function how an instance's
+// config differs from the one it replaces.
+func (rh *rpcHandler) auditChange(old, instance *instanceData) {
+	if rh.onConfigChange == nil {
+		return
+	}
+
+	rh.onConfigChange(ConfigChange{
+		Key:    instance.configMeta.Key,
+		OldSeq: old.configMeta.Seq,
+		NewSeq: instance.configMeta.Seq,
+		Fields: diffFields(reflect.ValueOf(old.config), reflect.ValueOf(instance.config), ""),
+	})
+}
+
+// diffFields lists the fields of the struct values a and b that differ,
+// descending into nested structs.  A nil pointer to a struct is compared
+// as the struct's zero value, so only the fields it sets are listed and
+// secret ones stay redacted.
+func diffFields(a, b reflect.Value, prefix string) []FieldChange {
+	for a.Kind() == reflect.Ptr && b.Kind() == reflect.Ptr {
+		if a.IsNil() && b.IsNil() {
+			return nil
+		}
+		a, b = elemOrZero(a), elemOrZero(b)
+	}
+	if a.Kind() != reflect.Struct || a.Type() != b.Type() {
+		return nil
+	}
+
+	changes := []FieldChange{}
+	t := a.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if _, ok := flattened(field); ok && field.IsExported() {
+			changes = append(changes, diffFields(a.Field(i), b.Field(i), prefix)...)
+			continue
+		}
+		if len(field.PkgPath) != 0 {
+			continue
+		}
+		name, ok := fieldName(field)
+		if !ok {
+			continue
+		}
+		path := prefix + name
+
+		av, bv := a.Field(i), b.Field(i)
+		if reflect.DeepEqual(av.Interface(), bv.Interface()) {
+			continue
+		}
+
+		nestedType := derefType(field.Type)
+		if nestedType.Kind() == reflect.Struct && nestedType != timeType {
+			nested := diffFields(av, bv, path+".")
+			if isSecret(field) {
+				for i := range nested {
+					nested[i].Old, nested[i].New = redacted, redacted
+				}
+			}
+			if len(nested) > 0 {
+				changes = append(changes, nested...)
+				continue
+			}
+		}
+
+		// as from nil to a zero struct: the whole value is shown only if
+		// it can't hold secrets
+		change := FieldChange{Path: path, Old: av.Interface(), New: bv.Interface()}
+		if isSecret(field) || hasSecrets(nestedType) {
+			change.Old, change.New = redacted, redacted
+		}
+		changes = append(changes, change)
+	}
+
+	return changes
+}
+
+// elemOrZero returns the value a pointer points to, or the zero value of
+// its element type if nil.
+func elemOrZero(v reflect.Value) reflect.Value {
+	if v.IsNil() {
+		return reflect.Zero(v.Type().Elem())
+	}
+	return v.Elem()
+}
+
+// hasSecrets tells whether values of type t hold secret fields, at any
+// depth.
+func hasSecrets(t reflect.Type) bool {
+	return hasSecretsSeen(t, map[reflect.Type]bool{})
+}
+
+func hasSecretsSeen(t reflect.Type, seen map[reflect.Type]bool) bool {
+	t = derefType(t)
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return hasSecretsSeen(t.Elem(), seen)
+	case reflect.Struct:
+	default:
+		return false
+	}
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+
+	for i := 0; i < t.NumField(); i++ {
+		if field := t.Field(i); isSecret(field) || hasSecretsSeen(field.Type, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSecret tells whether the values of a field shouldn't be shown.
+func isSecret(field reflect.StructField) bool {
+	for _, key := range []string{"encrypted", "referenceable"} {
+		if value, _ := kongTagValue(field, key); value == "true" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type auditedConfig struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Password string `json:"password" kong:"encrypted=true"`
+	Retry    struct {
+		Count int `json:"count"`
+		Delay int `json:"delay"`
+	} `json:"retry"`
+}
+
+func TestConfigAudit(t *testing.T) {
+	changes := []ConfigChange{}
+	rh := newRpcHandler(func() interface{} { return &auditedConfig{} }, "0.1", 1,
+		WithConfigAudit(func(change ConfigChange) { changes = append(changes, change) }))
+
+	var status InstanceStatus
+	assert.NoError(t, rh.StartInstance(PluginConfig{Name: "test",
+		Config: []byte(`{"host":"a","port":80,"password":"one","retry":{"count":1},"__key__":"k","__seq__":1}`)}, &status))
+	assert.Empty(t, changes)
+
+	assert.NoError(t, rh.StartInstance(PluginConfig{Name: "test",
+		Config: []byte(`{"host":"a","port":81,"password":"two","retry":{"count":2},"__key__":"k","__seq__":2}`)}, &status))
+	assert.Equal(t, []ConfigChange{{
+		Key:    "k",
+		OldSeq: 1,
+		NewSeq: 2,
+		Fields: []FieldChange{
+			{Path: "port", Old: 80, New: 81},
+			{Path: "password", Old: "[redacted]", New: "[redacted]"},
+			{Path: "retry.count", Old: 1, New: 2},
+		},
+	}}, changes)
+}
+
+type credentials struct {
+	User     string `json:"user"`
+	Password string `json:"password" kong:"encrypted=true"`
+}
+
+type upstreamConfig struct {
+	Name  string       `json:"name"`
+	Auth  *credentials `json:"auth"`
+	Proxy *struct {
+		Creds credentials `json:"creds"`
+	} `json:"proxy"`
+}
+
+func TestConfigAuditNilStruct(t *testing.T) {
+	changes := []ConfigChange{}
+	rh := newRpcHandler(func() interface{} { return &upstreamConfig{} }, "0.1", 1,
+		WithConfigAudit(func(change ConfigChange) { changes = append(changes, change) }))
+
+	var status InstanceStatus
+	for i, config := range []string{
+		`{"name":"a"}`,
+		`{"name":"a","auth":{"user":"ana","password":"hunter2"},"proxy":{"creds":{"password":"hunter3"}}}`,
+		`{"name":"a"}`,
+		`{"name":"a","auth":{}}`,
+	} {
+		data := []byte(fmt.Sprintf(`{"__key__":"k","__seq__":%d,%s`, i+1, config[1:]))
+		assert.NoError(t, rh.StartInstance(PluginConfig{Name: "test", Config: data}, &status))
+	}
+
+	assert.Len(t, changes, 3)
+	assert.Equal(t, []FieldChange{
+		{Path: "auth.user", Old: "", New: "ana"},
+		{Path: "auth.password", Old: "[redacted]", New: "[redacted]"},
+		{Path: "proxy.creds.password", Old: "[redacted]", New: "[redacted]"},
+	}, changes[0].Fields)
+	assert.Equal(t, []FieldChange{
+		{Path: "auth.user", Old: "ana", New: ""},
+		{Path: "auth.password", Old: "[redacted]", New: "[redacted]"},
+		{Path: "proxy.creds.password", Old: "[redacted]", New: "[redacted]"},
+	}, changes[1].Fields)
+	// nil to an empty struct holding a secret field
+	assert.Equal(t, []FieldChange{
+		{Path: "auth", Old: "[redacted]", New: "[redacted]"},
+	}, changes[2].Fields)
+	assert.NotContains(t, fmt.Sprint(changes), "hunter")
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// A DegradePolicy tells what to do with the events of a phase whose handler
+// keeps timing out, while it's short-circuited.
+type DegradePolicy int
+
+const (
+	// FailOpen skips the handler, letting requests through.
+	FailOpen DegradePolicy = iota
+	// FailClosed fails requests without calling the handler.
+	FailClosed
+)
+
+// timeoutBreaker counts the consecutive timeouts of an instance's phase
+// handler, and short-circuits it for a while once they reach the threshold
+// set by WithTimeoutBreaker.  A nil breaker never trips.
+type timeoutBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	lock        sync.Mutex
+	consecutive int
+	openUntil   time.Time
+}
+
+// newBreakers gives each handler of an instance its own breaker, if enabled.
+func (rh *rpcHandler) newBreakers(handlers map[string]PhaseFunc) map[string]*timeoutBreaker {
+	if rh.breakerThreshold <= 0 {
+		return nil
+	}
+
+	breakers := make(map[string]*timeoutBreaker, len(handlers))
+	for phase := range handlers {
+		breakers[phase] = &timeoutBreaker{threshold: rh.breakerThreshold, cooldown: rh.breakerCooldown}
+	}
+	return breakers
+}
+
+// open tells whether the handler is currently short-circuited.
+func (b *timeoutBreaker) open() bool {
+	if b == nil {
+		return false
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return time.Now().Before(b.openUntil)
+}
+
+// record counts the outcome of a handler call, and returns true if it
+// tripped the breaker.  After a cooldown, a single timeout trips it again,
+// until the handler completes in time.
+func (b *timeoutBreaker) record(timedOut bool) bool {
+	if b == nil {
+		return false
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if !timedOut {
+		b.consecutive = 0
+		return false
+	}
+
+	b.consecutive++
+	if b.consecutive < b.threshold {
+		return false
+	}
+	b.openUntil = time.Now().Add(b.cooldown)
+	return true
+}
+
+// shortCircuit handles an event of a short-circuited phase, according to
+// the policy given to WithTimeoutBreaker.
+func (rh *rpcHandler) shortCircuit(phase string) error {
+	if rh.metrics != nil {
+		rh.metrics.HandlerError(phase, failShortCircuit)
+	}
+	if rh.breakerPolicy == FailClosed {
+		return fmt.Errorf("%s handler short-circuited after repeated timeouts", phase)
+	}
+
+	return nil
+}
+
+func (rh *rpcHandler) logTripped(phase string) {
+	action := "skipping it"
+	if rh.breakerPolicy == FailClosed {
+		action = "failing its requests"
+	}
+	log.Printf("WARNING: %s handler timed out %d times in a row, %s for %s",
+		phase, rh.breakerThreshold, action, rh.breakerCooldown)
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Kong/go-pdk"
+	"github.com/stretchr/testify/assert"
+)
+
+type slowConfig struct {
+	calls *atomic.Int32
+}
+
+func (c slowConfig) Access(kong *pdk.PDK) {
+	c.calls.Add(1)
+	time.Sleep(50 * time.Millisecond)
+}
+
+func TestTimeoutBreaker(t *testing.T) {
+	for _, tc := range []struct {
+		policy DegradePolicy
+		err    string
+	}{
+		{FailOpen, ""},
+		{FailClosed, "access handler short-circuited after repeated timeouts"},
+	} {
+		var calls atomic.Int32
+		metrics := &countingMetrics{errors: map[[2]string]int{}}
+		rh := newRpcHandler(func() interface{} { return &slowConfig{calls: &calls} }, "0.1", 1,
+			WithPhaseTimeout(5*time.Millisecond), WithMetrics(metrics),
+			WithTimeoutBreaker(3, time.Minute, tc.policy))
+
+		var status InstanceStatus
+		assert.NoError(t, rh.StartInstance(PluginConfig{Name: "test", Config: []byte(`{"__seq__":1}`)}, &status))
+
+		for i := 0; i < 3; i++ {
+			assert.EqualError(t, dispatch(t, rh, status.Id, "access"), "access handler timed out after 5ms")
+		}
+		for i := 0; i < 2; i++ {
+			err := dispatch(t, rh, status.Id, "access")
+			if tc.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.err)
+			}
+		}
+
+		assert.Equal(t, int32(3), calls.Load())
+		metrics.lock.Lock()
+		assert.Equal(t, map[[2]string]int{
+			{"access", "timeout"}:       3,
+			{"access", "short_circuit"}: 2,
+		}, metrics.errors)
+		metrics.lock.Unlock()
+	}
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+const pdkModule = "github.com/Kong/go-pdk"
+
+// pdkVersion returns the version of go-pdk the running binary was built
+// with, as recorded in its build info: "(devel)" when built from within the
+// module itself, and empty if unknown.
+func pdkVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+
+	if info.Main.Path == pdkModule {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path != pdkModule {
+			continue
+		}
+		if dep.Replace != nil && dep.Replace.Version != "" {
+			return dep.Replace.Version
+		}
+		return dep.Version
+	}
+
+	return ""
+}
+
+// goVersion returns the version of the Go toolchain that built the binary.
+func goVersion() string {
+	return runtime.Version()
+}
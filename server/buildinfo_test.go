@@ -0,0 +1,22 @@
+package server
+
+import (
+	"bytes"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildVersions(t *testing.T) {
+	rh := newRpcHandler(func() interface{} { return &multiProtocolConfig{} }, "0.1", 1, WithName("test"))
+	info, err := rh.getInfo()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, info.PdkVersion)
+	assert.Equal(t, runtime.Version(), info.GoVersion)
+
+	var buf bytes.Buffer
+	assert.NoError(t, writeInfo(&buf, rh, "/tmp/test.socket"))
+	assert.Contains(t, buf.String(), `"PdkVersion":"`+info.PdkVersion+`"`)
+	assert.Contains(t, buf.String(), `"GoVersion":"`+runtime.Version()+`"`)
+}
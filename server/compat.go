@@ -0,0 +1,114 @@
+package server
+
+import (
+	"fmt"
+	"slices"
+)
+
+// Attributes of field declarations known to every Kong release this server
+// supports.  Those added later are in keyMinVersions.
+var baseFieldKeys = []string{
+	"type", "required", "default", "description", "elements", "keys", "values", "fields",
+	"one_of", "not_one_of", "between", "gt", "eq", "ne", "len_eq", "len_min", "len_max",
+	"match", "not_match", "match_all", "match_any", "match_none", "starts_with", "contains",
+	"unique", "auto", "immutable", "uuid", "timestamp", "custom_validator",
+}
+
+// Field types known to Kong.
+var kongTypes = []string{"string", "boolean", "integer", "number", "array", "set", "map", "record", "json"}
+
+// Keys of a plugin schema, besides those of its fields.
+var schemaKeys = []string{"name", "fields", "entity_checks", "shorthand_fields"}
+
+// AssertKongCompatible checks that a plugin schema, as returned by Describe,
+// only uses the keys and types known to the given Kong version (as in "2.8"
+// or "3.4.1"), returning the incompatibilities found.  It's meant for tests
+// of plugins targeting older Kong releases.
+func AssertKongCompatible(schema map[string]interface{}, kongVersion string) ([]string, error) {
+	v, err := parseKongVersion(kongVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	c := compatChecker{version: v, problems: []string{}}
+	for _, key := range sortedKeys(schema) {
+		if !slices.Contains(schemaKeys, key) {
+			c.reportf("%q isn't a plugin schema key", key)
+		}
+	}
+	c.fields(schema["fields"], "")
+
+	return c.problems, nil
+}
+
+type compatChecker struct {
+	version  kongVersion
+	problems []string
+}
+
+func (c *compatChecker) reportf(format string, args ...interface{}) {
+	c.problems = append(c.problems, fmt.Sprintf(format, args...))
+}
+
+// fields checks a list of field declarations, each a map from the field
+// name to its attributes.
+func (c *compatChecker) fields(fields interface{}, path string) {
+	var list []map[string]interface{}
+	switch fields := fields.(type) {
+	case []schemaDict:
+		for _, field := range fields {
+			list = append(list, field)
+		}
+	case []map[string]interface{}:
+		list = fields
+	case []interface{}:
+		for _, field := range fields {
+			if field, ok := asDict(field); ok {
+				list = append(list, field)
+			}
+		}
+	}
+
+	for _, field := range list {
+		for _, name := range sortedKeys(field) {
+			if decl, ok := asDict(field[name]); ok {
+				c.decl(decl, join(path, name))
+			}
+		}
+	}
+}
+
+// decl checks the declaration of a field, and those nested in it.
+func (c *compatChecker) decl(decl map[string]interface{}, path string) {
+	for _, key := range sortedKeys(decl) {
+		if minVersion, ok := keyMinVersions[key]; ok {
+			if c.version.less(minVersion) {
+				c.reportf("%s: %s needs Kong %s", path, key, minVersion)
+			}
+		} else if !slices.Contains(baseFieldKeys, key) {
+			c.reportf("%s: %q isn't a Kong field attribute", path, key)
+		}
+	}
+
+	if t, ok := decl["type"].(string); ok && !slices.Contains(kongTypes, t) {
+		c.reportf("%s: %q isn't a Kong type", path, t)
+	}
+
+	for _, key := range []string{"elements", "keys", "values"} {
+		if nested, ok := asDict(decl[key]); ok {
+			c.decl(nested, path+"."+key)
+		}
+	}
+	c.fields(decl["fields"], path)
+}
+
+func asDict(v interface{}) (map[string]interface{}, bool) {
+	switch v := v.(type) {
+	case schemaDict:
+		return v, true
+	case map[string]interface{}:
+		return v, true
+	}
+
+	return nil, false
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAssertKongCompatible(t *testing.T) {
+	type Config struct {
+		Password string            `json:"password" kong:"referenceable=true"`
+		Headers  map[string]string `json:"headers" kong:"values.one_of=a;b"`
+		Header   string            `json:"header" kong:"transform=lower"`
+	}
+	description, err := Describe(func() interface{} { return &Config{} }, "0.1", 1, WithName("test"))
+	assert.NoError(t, err)
+
+	problems, err := AssertKongCompatible(description.Schema, "3.4")
+	assert.NoError(t, err)
+	assert.Empty(t, problems)
+
+	problems, err = AssertKongCompatible(description.Schema, "2.1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"config.password: referenceable needs Kong 2.8.0"}, problems)
+
+	problems, err = AssertKongCompatible(map[string]interface{}{
+		"name":     "test",
+		"typedefs": map[string]interface{}{},
+		"fields": []interface{}{
+			map[string]interface{}{"config": map[string]interface{}{
+				"type": "record",
+				"fields": []interface{}{
+					map[string]interface{}{"x": map[string]interface{}{"type": "blob", "shiny": true}},
+				},
+			}},
+		},
+	}, "3.4")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		`"typedefs" isn't a plugin schema key`,
+		`config.x: "shiny" isn't a Kong field attribute`,
+		`config.x: "blob" isn't a Kong type`,
+	}, problems)
+
+	_, err = AssertKongCompatible(description.Schema, "three")
+	assert.EqualError(t, err, `invalid Kong version "three"`)
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// loadDefaults reads the file given by WithDefaultsFile, if any.
+func (rh *rpcHandler) loadDefaults() error {
+	if rh.defaultsFile == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(rh.defaultsFile)
+	if err != nil {
+		return err
+	}
+	if err := decodeNumbers(data, &rh.configDefaults); err != nil {
+		return fmt.Errorf("decoding %s: %w", rh.defaultsFile, err)
+	}
+
+	return nil
+}
+
+// withConfigDefaults fills the fields of the configuration data that are
+// missing or null with the values from the WithDefaultsFile file.
+func (rh *rpcHandler) withConfigDefaults(data []byte) ([]byte, error) {
+	if rh.configDefaults == nil {
+		return data, nil
+	}
+
+	var config map[string]interface{}
+	if err := decodeNumbers(data, &config); err != nil {
+		return nil, fmt.Errorf("decoding config: %w", err)
+	}
+	if config == nil {
+		config = map[string]interface{}{}
+	}
+
+	mergeDefaults(config, rh.configDefaults)
+	return json.Marshal(config)
+}
+
+// decodeNumbers decodes JSON data into v keeping numbers as json.Number, so
+// integers too large for a float64 are passed through as they are.
+func decodeNumbers(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// mergeDefaults sets the missing or null keys of config to their value in
+// defaults, descending into objects found in both.
+func mergeDefaults(config, defaults map[string]interface{}) {
+	for key, def := range defaults {
+		value, ok := config[key]
+		if !ok || value == nil {
+			config[key] = def
+			continue
+		}
+
+		nested, ok := value.(map[string]interface{})
+		nestedDefaults, ok2 := def.(map[string]interface{})
+		if ok && ok2 {
+			mergeDefaults(nested, nestedDefaults)
+		}
+	}
+}
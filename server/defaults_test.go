@@ -0,0 +1,55 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultsFile(t *testing.T) {
+	type Config struct {
+		Host    string `json:"host"`
+		Port    int    `json:"port"`
+		Options struct {
+			Retries int  `json:"retries"`
+			Verbose bool `json:"verbose"`
+		} `json:"options"`
+	}
+	constructor := func() interface{} { return &Config{} }
+
+	path := filepath.Join(t.TempDir(), "defaults.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"host":"localhost","port":8080,"options":{"retries":3,"verbose":true}}`), 0o600))
+	rh := newRpcHandler(constructor, "0.1", 1, WithDefaultsFile(path))
+
+	var status InstanceStatus
+	assert.NoError(t, rh.StartInstance(PluginConfig{Name: "test",
+		Config: []byte(`{"host":"example.com","port":null,"options":{"verbose":false}}`)}, &status))
+	config := status.Config.(*Config)
+	assert.Equal(t, "example.com", config.Host)
+	assert.Equal(t, 8080, config.Port)
+	assert.Equal(t, 3, config.Options.Retries)
+	assert.False(t, config.Options.Verbose)
+
+	assert.Nil(t, newRpcHandler(constructor, "0.1", 1, WithDefaultsFile(filepath.Join(t.TempDir(), "missing.json"))))
+}
+
+func TestDefaultsLargeIntegers(t *testing.T) {
+	type Config struct {
+		Id    int64 `json:"id"`
+		Quota int64 `json:"quota"`
+	}
+
+	// both above 2^53, which a float64 can't hold exactly
+	path := filepath.Join(t.TempDir(), "defaults.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"quota":9007199254740993}`), 0o600))
+	rh := newRpcHandler(func() interface{} { return &Config{} }, "0.1", 1, WithDefaultsFile(path))
+
+	var status InstanceStatus
+	assert.NoError(t, rh.StartInstance(PluginConfig{Name: "test",
+		Config: []byte(`{"id":9223372036854775807}`)}, &status))
+	config := status.Config.(*Config)
+	assert.Equal(t, int64(9223372036854775807), config.Id)
+	assert.Equal(t, int64(9007199254740993), config.Quota)
+}
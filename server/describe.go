@@ -0,0 +1,42 @@
+package server
+
+// A PluginDescription is what the plugin server tells Kong about a plugin,
+// for use by tooling in the same process.
+type PluginDescription struct {
+	Name     string                 // plugin name
+	Version  string                 // version number
+	Priority int                    // priority info
+	Phases   []string               // events it can handle
+	Schema   map[string]interface{} // representation of the config schema
+
+	PdkVersion string // go-pdk version the plugin was built with, if known
+	GoVersion  string // Go toolchain the plugin was built with
+}
+
+// Describe returns the description of the plugin StartServer would serve
+// given the same arguments, without starting it.
+func Describe(constructor func() interface{}, version string, priority int, opts ...ServerOption) (PluginDescription, error) {
+	rh, err := buildRpcHandler(constructor, version, priority, opts...)
+	if err != nil {
+		return PluginDescription{}, err
+	}
+
+	// only for tooling, Kong doesn't know it
+	rh.schemaOptions.nullable = rh.nullablePointers
+
+	info, err := rh.getInfo()
+	if err != nil {
+		return PluginDescription{}, err
+	}
+
+	return PluginDescription{
+		Name:     info.Name,
+		Version:  info.Version,
+		Priority: info.Priority,
+		Phases:   info.Phases,
+		Schema:   info.Schema,
+
+		PdkVersion: info.PdkVersion,
+		GoVersion:  info.GoVersion,
+	}, nil
+}
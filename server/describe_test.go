@@ -0,0 +1,36 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDescribe(t *testing.T) {
+	constructor := func() interface{} { return &multiProtocolConfig{} }
+
+	description, err := Describe(constructor, "1.2.3", 10, WithName("test"))
+	assert.NoError(t, err)
+	assert.Equal(t, "test", description.Name)
+	assert.Equal(t, "1.2.3", description.Version)
+	assert.Equal(t, 10, description.Priority)
+	assert.Equal(t, []string{"access", "preread", "log"}, description.Phases)
+	assert.Equal(t, map[string]interface{}{
+		"name": "test",
+		"fields": []schemaDict{
+			{"protocols": schemaDict{
+				"type":     "set",
+				"required": true,
+				"default":  []string{"http", "https", "grpc", "grpcs", "tcp", "tls", "udp"},
+				"elements": schemaDict{
+					"type":   "string",
+					"one_of": []string{"http", "https", "grpc", "grpcs", "tcp", "tls", "udp"},
+				},
+			}},
+			{"config": schemaDict{"type": "record", "fields": []schemaDict{}}},
+		},
+	}, description.Schema)
+
+	_, err = Describe(func() interface{} { return pointerPhaseConfig{} }, "1.2.3", 10)
+	assert.EqualError(t, err, "invalid config type: Access method is defined on *server.pointerPhaseConfig, but the constructor returns a server.pointerPhaseConfig")
+}
@@ -0,0 +1,133 @@
+package server
+
+import (
+	"reflect"
+	"strconv"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Units a time.Duration field can be given in Kong's configuration, with the
+// `unit` tag.  Without one, the value is a number of nanoseconds, which is
+// how encoding/json reads it.
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+}
+
+// durationUnit returns the unit of a time.Duration field, as given by its
+// `unit` tag.
+func durationUnit(field reflect.StructField) (time.Duration, bool) {
+	name, ok := kongTagValue(field, "unit")
+	if !ok {
+		return time.Nanosecond, true
+	}
+
+	unit, ok := durationUnits[name]
+	return unit, ok
+}
+
+// withDurationUnit completes the declaration of a time.Duration field: the
+// default is given as a number of the field's units, or as a Go duration
+// ("1m30s"), and emitted as a number of those units.
+func (b *schemaBuilder) withDurationUnit(decl schemaDict, field reflect.StructField, path string) {
+	unit, ok := durationUnit(field)
+	if !ok {
+		name, _ := kongTagValue(field, "unit")
+		b.warnf("field %s: unknown unit %q", path, name)
+		delete(decl, "default")
+		return
+	}
+
+	def, ok := decl["default"].(string)
+	if !ok {
+		return
+	}
+
+	if n, err := strconv.Atoi(def); err == nil {
+		decl["default"] = n
+		return
+	}
+
+	d, err := time.ParseDuration(def)
+	if err != nil || d%unit != 0 {
+		b.warnf("field %s: default %q isn't a whole number of %s", path, def, unit)
+		delete(decl, "default")
+		return
+	}
+	decl["default"] = int(d / unit)
+}
+
+// scaleDurations converts the time.Duration fields of a decoded config
+// from the number of units Kong sent into actual durations, including those
+// of records in arrays and maps.
+func scaleDurations(v reflect.Value) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if forEachElem(v, scaleDurations) {
+		return
+	}
+	if v.Kind() != reflect.Struct {
+		return
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if len(field.PkgPath) != 0 {
+			continue
+		}
+
+		value := v.Field(i)
+		if derefType(field.Type) != durationType {
+			scaleDurations(value)
+			continue
+		}
+
+		for value.Kind() == reflect.Ptr && !value.IsNil() {
+			value = value.Elem()
+		}
+		if unit, ok := durationUnit(field); ok && value.Kind() == reflect.Int64 {
+			value.SetInt(value.Int() * int64(unit))
+		}
+	}
+}
+
+// forEachElem calls f on the elements of v if it's an array or a map
+// possibly holding records, reporting whether it's one.  Map values aren't
+// addressable: f gets a copy, put back after.
+func forEachElem(v reflect.Value, f func(reflect.Value)) bool {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			f(v.Index(i))
+		}
+		return true
+
+	case reflect.Map:
+		switch derefType(v.Type().Elem()).Kind() {
+		case reflect.Struct, reflect.Slice, reflect.Array, reflect.Map:
+		default:
+			return true
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(iter.Value())
+			f(elem)
+			v.SetMapIndex(iter.Key(), elem)
+		}
+		return true
+	}
+
+	return false
+}
@@ -2,6 +2,11 @@ package server
 
 import (
 	"fmt"
+	"log"
+	"runtime/debug"
+	"time"
+
+	"github.com/Kong/go-pdk"
 )
 
 // Incoming data for a new event.
@@ -40,3 +45,78 @@ func (rh *rpcHandler) Step(in StepData, out *StepData) error {
 
 	return nil
 }
+
+// Kinds of handler failures, as reported to Metrics.
+const (
+	failPanic   = "panic"
+	failTimeout = "timeout"
+	failError   = "error"
+
+	failShortCircuit = "short_circuit"
+)
+
+// handleEvent runs the handler of an event, unless its breaker is open,
+// then releases what the event used from the request store, whether the
+// handler succeeded or not.
+func (rh *rpcHandler) handleEvent(phase string, h PhaseFunc, breaker *timeoutBreaker, kong *pdk.PDK) error {
+	defer requestStores.endEvent(kong, rh.lastPhases[phase])
+
+	if breaker.open() {
+		return rh.shortCircuit(phase)
+	}
+
+	failure, err := rh.runHandler(phase, h, kong)
+	if breaker.record(failure == failTimeout) {
+		rh.logTripped(phase)
+	}
+	return err
+}
+
+// runHandler calls the handler of a phase, recovering from panics and
+// waiting at most for the timeout set by WithPhaseTimeout.  Panics and
+// returned errors are only logged, but a timed out handler could still be
+// talking to Kong, so the error returned then must drop the connection.
+// Also returns the kind of failure, if any.
+func (rh *rpcHandler) runHandler(phase string, h PhaseFunc, kong *pdk.PDK) (string, error) {
+	done := make(chan string, 1)
+	run := func() {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("panic in %s handler: %v\n%s", phase, r, debug.Stack())
+				done <- failPanic
+			}
+		}()
+
+		if err := h(kong); err != nil {
+			log.Printf("error in %s handler: %s", phase, err)
+			done <- failError
+			return
+		}
+		done <- ""
+	}
+
+	var failure string
+	if rh.phaseTimeout == 0 {
+		run()
+		failure = <-done
+	} else {
+		go run()
+		select {
+		case failure = <-done:
+		case <-time.After(rh.phaseTimeout):
+			failure = failTimeout
+		}
+	}
+
+	if failure == "" {
+		return "", nil
+	}
+	if rh.metrics != nil {
+		rh.metrics.HandlerError(phase, failure)
+	}
+	if failure == failTimeout {
+		return failure, fmt.Errorf("%s handler timed out after %s", phase, rh.phaseTimeout)
+	}
+
+	return failure, nil
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Kong/go-pdk"
+	"github.com/stretchr/testify/assert"
+)
+
+type countingMetrics struct {
+	lock   sync.Mutex
+	errors map[[2]string]int
+}
+
+func (m *countingMetrics) HandlerError(phase, kind string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.errors[[2]string{phase, kind}]++
+}
+
+type failingConfig struct{}
+
+func (c failingConfig) Access(kong *pdk.PDK) {
+	time.Sleep(100 * time.Millisecond)
+}
+
+func (c failingConfig) Response(kong *pdk.PDK) {
+	panic("oops")
+}
+
+func (c failingConfig) Log(kong *pdk.PDK) error {
+	return errors.New("can't log")
+}
+
+func TestHandlerErrorMetrics(t *testing.T) {
+	metrics := &countingMetrics{errors: map[[2]string]int{}}
+	rh := newRpcHandler(func() interface{} { return &failingConfig{} }, "0.1", 1,
+		WithPhaseTimeout(10*time.Millisecond), WithMetrics(metrics))
+
+	var status InstanceStatus
+	assert.NoError(t, rh.StartInstance(PluginConfig{Name: "test", Config: []byte(`{"__seq__":1}`)}, &status))
+
+	assert.EqualError(t, dispatch(t, rh, status.Id, "access"), "access handler timed out after 10ms")
+	assert.NoError(t, dispatch(t, rh, status.Id, "response"))
+	assert.NoError(t, dispatch(t, rh, status.Id, "log"))
+	assert.NoError(t, dispatch(t, rh, status.Id, "log"))
+
+	metrics.lock.Lock()
+	defer metrics.lock.Unlock()
+	assert.Equal(t, map[[2]string]int{
+		{"access", "timeout"}: 1,
+		{"response", "panic"}: 1,
+		{"log", "error"}:      2,
+	}, metrics.errors)
+}
@@ -0,0 +1,212 @@
+package server
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/Kong/go-pdk/server/kong_plugin_protocol"
+	"google.golang.org/protobuf/proto"
+)
+
+// A PDKHandler answers the PDK calls a plugin makes while handling an
+// event: given the method name (as in "kong.request.get_header") and its
+// arguments, serialized as protobuf, it returns the serialized result.
+// The Handle method of a test.TestEnv is one.
+type PDKHandler func(method string, args []byte) []byte
+
+// A FakeKong drives a plugin server the way Kong does, sending the same RPC
+// calls over a connection served as a real one, for end-to-end tests of a
+// plugin.  It isn't safe for concurrent use.
+type FakeKong struct {
+	rh      *rpcHandler
+	conn    net.Conn
+	pdk     PDKHandler
+	seq     int64
+	stopped chan struct{} // closed when the server stops serving conn
+	err     error         // that stopped the server, once stopped
+}
+
+// NewFakeKong starts serving a plugin, given as to StartServer, to a fake
+// Kong.  PDK calls are answered by handler; if nil, with empty results.
+func NewFakeKong(constructor func() interface{}, version string, priority int, handler PDKHandler, opts ...ServerOption) (*FakeKong, error) {
+	rh, err := buildRpcHandler(constructor, version, priority, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return newFakeKong(rh, handler), nil
+}
+
+// newFakeKong starts serving rh to a fake Kong, on a connection of its own.
+func newFakeKong(rh *rpcHandler, handler PDKHandler) *FakeKong {
+	kong, plugin := net.Pipe()
+	k := &FakeKong{
+		rh:      rh,
+		conn:    kong,
+		pdk:     handler,
+		stopped: make(chan struct{}),
+	}
+	go func() {
+		k.err = serveConn(plugin, rh)
+		close(k.stopped)
+	}()
+
+	return k
+}
+
+// Close closes the connection to the plugin server.
+func (k *FakeKong) Close() error {
+	err := k.conn.Close()
+	<-k.stopped
+	return err
+}
+
+// GetPluginInfo asks for the plugin's description, as Kong does on startup.
+func (k *FakeKong) GetPluginInfo() (*kong_plugin_protocol.PluginInfo, error) {
+	ret, err := k.call(&kong_plugin_protocol.RpcCall{
+		Call: &kong_plugin_protocol.RpcCall_CmdGetPluginInfo{
+			CmdGetPluginInfo: &kong_plugin_protocol.CmdGetPluginInfo{},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ret.GetPluginInfo(), nil
+}
+
+// StartInstance starts an instance of the plugin with the given name, from
+// its configuration data (a JSON object).
+func (k *FakeKong) StartInstance(name string, config []byte) (*kong_plugin_protocol.InstanceStatus, error) {
+	ret, err := k.call(&kong_plugin_protocol.RpcCall{
+		Call: &kong_plugin_protocol.RpcCall_CmdStartInstance{
+			CmdStartInstance: &kong_plugin_protocol.CmdStartInstance{Name: name, Config: config},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ret.GetInstanceStatus(), nil
+}
+
+// InstanceStatus asks for the status of an instance.
+func (k *FakeKong) InstanceStatus(id int32) (*kong_plugin_protocol.InstanceStatus, error) {
+	ret, err := k.call(&kong_plugin_protocol.RpcCall{
+		Call: &kong_plugin_protocol.RpcCall_CmdGetInstanceStatus{
+			CmdGetInstanceStatus: &kong_plugin_protocol.CmdGetInstanceStatus{InstanceId: id},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ret.GetInstanceStatus(), nil
+}
+
+// InstanceStats returns the details of an instance, such as its revision
+// and config hash.  Kong has no call for them: they're read from the
+// plugin server directly.
+func (k *FakeKong) InstanceStats(id int32) (InstanceStats, error) {
+	var stats InstanceStats
+	err := k.rh.InstanceStats(int(id), &stats)
+	return stats, err
+}
+
+// CloseInstance closes an instance.
+func (k *FakeKong) CloseInstance(id int32) (*kong_plugin_protocol.InstanceStatus, error) {
+	ret, err := k.call(&kong_plugin_protocol.RpcCall{
+		Call: &kong_plugin_protocol.RpcCall_CmdCloseInstance{
+			CmdCloseInstance: &kong_plugin_protocol.CmdCloseInstance{InstanceId: id},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ret.GetInstanceStatus(), nil
+}
+
+// HandleEvent has an instance handle an event (as in "access"), answering
+// its PDK calls with the handler given to NewFakeKong until it's done.
+func (k *FakeKong) HandleEvent(id int32, event string) error {
+	_, err := k.call(&kong_plugin_protocol.RpcCall{
+		Call: &kong_plugin_protocol.RpcCall_CmdHandleEvent{
+			CmdHandleEvent: &kong_plugin_protocol.CmdHandleEvent{InstanceId: id, EventName: event},
+		},
+	})
+	return err
+}
+
+// call sends an RPC call and returns its result.  Until then, frames from
+// the plugin server are PDK calls, ended by an empty one for events.
+func (k *FakeKong) call(m *kong_plugin_protocol.RpcCall) (*kong_plugin_protocol.RpcReturn, error) {
+	k.seq++
+	m.Sequence = k.seq
+
+	d, err := proto.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	if err := writePbFrame(k.conn, d); err != nil {
+		return nil, k.serverErr(err)
+	}
+
+	if _, ok := m.Call.(*kong_plugin_protocol.RpcCall_CmdHandleEvent); ok {
+		if err := k.answerPDK(); err != nil {
+			return nil, k.serverErr(err)
+		}
+	}
+
+	d, err = readPbFrame(k.conn)
+	if err != nil {
+		return nil, k.serverErr(err)
+	}
+
+	var ret kong_plugin_protocol.RpcReturn
+	if err := proto.Unmarshal(d, &ret); err != nil {
+		return nil, err
+	}
+	if ret.Sequence != m.Sequence {
+		return nil, fmt.Errorf("got the result of call %d, expected %d", ret.Sequence, m.Sequence)
+	}
+
+	return &ret, nil
+}
+
+// answerPDK answers the PDK calls of an event handler until it's done.
+func (k *FakeKong) answerPDK() error {
+	for {
+		method, err := readPbFrame(k.conn)
+		if err != nil {
+			return err
+		}
+		if len(method) == 0 {
+			return nil
+		}
+
+		args, err := readPbFrame(k.conn)
+		if err != nil {
+			return err
+		}
+
+		var out []byte
+		if k.pdk != nil {
+			out = k.pdk(string(method), args)
+		}
+		if err := writePbFrame(k.conn, out); err != nil {
+			return err
+		}
+	}
+}
+
+// serverErr returns the error that made the plugin server drop the
+// connection, if that's why talking to it failed with err.
+func (k *FakeKong) serverErr(err error) error {
+	k.conn.Close()
+	<-k.stopped
+	if k.err != nil {
+		return fmt.Errorf("plugin server: %w", k.err)
+	}
+	return err
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/Kong/go-pdk"
+	"github.com/Kong/go-pdk/bridge"
+	"github.com/Kong/go-pdk/server/kong_plugin_protocol"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+type greeterConfig struct {
+	Greeting string `json:"greeting"`
+}
+
+func (c greeterConfig) Access(kong *pdk.PDK) {
+	name, _ := kong.Request.GetHeader("x-name")
+	_ = kong.Response.SetHeader("x-greeting", c.Greeting+", "+name)
+}
+
+func TestFakeKong(t *testing.T) {
+	headers := map[string]string{}
+	handler := func(method string, args []byte) []byte {
+		switch method {
+		case "kong.request.get_header":
+			out, _ := proto.Marshal(bridge.WrapString("Ana"))
+			return out
+		case "kong.response.set_header":
+			var kv kong_plugin_protocol.KV
+			assert.NoError(t, proto.Unmarshal(args, &kv))
+			headers[kv.K] = kv.V.GetStringValue()
+		}
+		return nil
+	}
+
+	kong, err := NewFakeKong(func() interface{} { return &greeterConfig{} }, "0.1", 10, handler, WithName("greeter"))
+	assert.NoError(t, err)
+	defer kong.Close()
+
+	info, err := kong.GetPluginInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, "greeter", info.Name)
+	assert.Equal(t, []string{"access"}, info.Phases)
+	assert.True(t, json.Valid([]byte(info.Schema)))
+
+	status, err := kong.StartInstance("greeter", []byte(`{"__seq__":4,"greeting":"Hello"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, int32(4), status.InstanceId)
+
+	assert.NoError(t, kong.HandleEvent(4, "access"))
+	assert.Equal(t, map[string]string{"x-greeting": "Hello, Ana"}, headers)
+
+	status, err = kong.CloseInstance(4)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(4), status.InstanceId)
+
+	// the server drops the connection on failed calls, as with Kong
+	assert.EqualError(t, kong.HandleEvent(4, "access"), "plugin server: no plugin instance 4")
+	_, err = kong.GetPluginInfo()
+	assert.Error(t, err)
+}
+
+func TestFakeKongInstanceStats(t *testing.T) {
+	kong, err := NewFakeKong(func() interface{} { return &greeterConfig{} }, "0.1", 10, nil)
+	assert.NoError(t, err)
+	defer kong.Close()
+
+	first, err := kong.StartInstance("greeter", []byte(`{"greeting":"hi","__key__":"k","__seq__":1}`))
+	assert.NoError(t, err)
+	second, err := kong.StartInstance("greeter", []byte(`{"greeting":"hello","__key__":"k","__seq__":2}`))
+	assert.NoError(t, err)
+
+	stats, err := kong.InstanceStats(second.InstanceId)
+	assert.NoError(t, err)
+	assert.Equal(t, int(second.InstanceId), stats.Id)
+	assert.Equal(t, "k", stats.Key)
+	assert.Equal(t, 2, stats.Revision)
+	assert.Len(t, stats.ConfigHash, 64)
+
+	firstStats, err := kong.InstanceStats(first.InstanceId)
+	assert.NoError(t, err)
+	assert.NotEqual(t, firstStats.ConfigHash, stats.ConfigHash)
+
+	// without a plugin key, there's nothing to count revisions by
+	for seq := 3; seq <= 4; seq++ {
+		status, err := kong.StartInstance("greeter", []byte(fmt.Sprintf(`{"greeting":"hi","__seq__":%d}`, seq)))
+		assert.NoError(t, err)
+		stats, err := kong.InstanceStats(status.InstanceId)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, stats.Revision)
+	}
+
+	_, err = kong.InstanceStats(42)
+	assert.EqualError(t, err, "no plugin instance 42")
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// withFlags handles the `flags` Kong tag of an integer used as a bitmask,
+// naming its bits as ';'-separated "name:bit" items, for example
+// `kong:"flags=read:1;write:2;admin:4"`.  Kong has no such validator: the
+// flags are listed in the field's description, and unless given a between
+// tag, the value is bounded to the combination of all of them, replacing
+// the bound that comes with unsigned kinds.
+func (b *schemaBuilder) withFlags(decl schemaDict, value string, between bool, path string) {
+	if decl["type"] != "integer" {
+		b.warnf("field %s: flags are only for integers", path)
+		return
+	}
+
+	all := 0
+	names := []string{}
+	for _, item := range strings.Split(value, ";") {
+		name, bit, ok := strings.Cut(item, ":")
+		n, err := strconv.Atoi(bit)
+		if !ok || name == "" || err != nil {
+			b.warnf("field %s: malformed flag %q, expected name:bit", path, item)
+			return
+		}
+		if n <= 0 || bits.OnesCount(uint(n)) != 1 {
+			b.warnf("field %s: flag %s is %d, not a single bit", path, name, n)
+			return
+		}
+		if all&n != 0 {
+			b.warnf("field %s: flag %s reuses bit %d", path, name, n)
+			return
+		}
+		all |= n
+		names = append(names, fmt.Sprintf("%s (%d)", name, n))
+	}
+
+	message := "Flags: " + strings.Join(names, ", ")
+	if description, ok := decl["description"].(string); ok {
+		message = description + ". " + message
+	}
+	decl["description"] = message
+
+	if !between {
+		decl["between"] = []int{0, all}
+	}
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlags(t *testing.T) {
+	type Config struct {
+		Permissions int    `json:"permissions" kong:"flags=read:1;write:2;admin:4"`
+		Bounded     int    `json:"bounded" kong:"flags=read:1;write:2,between=0;1"`
+		Unsigned    uint32 `json:"unsigned" kong:"flags=read:1;write:2"`
+		UnsignedMax uint32 `json:"unsigned_max" kong:"flags=read:1;write:2,between=0;2"`
+		Odd         int    `json:"odd" kong:"flags=read:1;write:3"`
+		Name        string `json:"name" kong:"flags=read:1"`
+	}
+
+	b := &schemaBuilder{}
+	schema := b.getSchemaDict(reflect.TypeOf(Config{}), "config")
+	assert.Equal(t, []schemaDict{
+		{"permissions": schemaDict{
+			"type":        "integer",
+			"description": "Flags: read (1), write (2), admin (4)",
+			"between":     []int{0, 7},
+		}},
+		{"bounded": schemaDict{
+			"type":        "integer",
+			"description": "Flags: read (1), write (2)",
+			"between":     []int{0, 1},
+		}},
+		{"unsigned": schemaDict{
+			"type":        "integer",
+			"description": "Flags: read (1), write (2)",
+			"between":     []int{0, 3},
+		}},
+		{"unsigned_max": schemaDict{
+			"type":        "integer",
+			"description": "Flags: read (1), write (2)",
+			"between":     []int{0, 2},
+		}},
+		{"odd": schemaDict{"type": "integer"}},
+		{"name": schemaDict{"type": "string"}},
+	}, schema["fields"])
+	assert.Equal(t, []string{
+		"field config.odd: flag write is 3, not a single bit",
+		"field config.name: flags are only for integers",
+	}, b.warnings)
+}
@@ -7,10 +7,13 @@ import (
 	"log"
 	"time"
 	"math/rand"
+	"reflect"
+	"strings"
 )
 
 type configMetadata struct {
-       Seq int `json:"__seq__"`
+       Seq int    `json:"__seq__"`
+       Key string `json:"__key__"` // identifies the plugin across config changes
 }
 
 type instanceData struct {
@@ -18,8 +21,14 @@ type instanceData struct {
 	startTime     time.Time
 	config        interface{}
 	configMeta    configMetadata
-	handlers      map[string]func(*pdk.PDK)
+	handlers      map[string]PhaseFunc
+	breakers      map[string]*timeoutBreaker
 	lastEventTime time.Time
+	size          int           // as reported by its Size method, if any
+	ready         chan struct{} // closed once configured, if done asynchronously
+	configErr     error         // from the Configure hook, once ready
+	configHash    string        // of the configuration data, see configHash
+	revision      int           // of the plugin key's instances, from 1
 }
 
 // Configuration data for a new plugin instance.
@@ -37,22 +46,56 @@ type (
 	logger       interface{ Log(*pdk.PDK) }
 )
 
-func getHandlers(config interface{}) map[string]func(*pdk.PDK) {
-	handlers := map[string]func(*pdk.PDK){}
+// Handlers can also return an error, which is logged.
+type (
+	certificateErrer interface{ Certificate(*pdk.PDK) error }
+	rewriteErrer     interface{ Rewrite(*pdk.PDK) error }
+	accessErrer      interface{ Access(*pdk.PDK) error }
+	responseErrer    interface{ Response(*pdk.PDK) error }
+	prereadErrer     interface{ Preread(*pdk.PDK) error }
+	logErrer         interface{ Log(*pdk.PDK) error }
+)
+
+// Optional hooks, run in this order when an instance is created.
+type (
+	validater  interface{ Validate() error }
+	configurer interface{ Configure() error }
+)
+
+func getHandlers(config interface{}) map[string]PhaseFunc {
+	handlers := map[string]PhaseFunc{}
+
+	if h, ok := config.(certificater); ok { handlers["certificate"] = noError(h.Certificate) }
+	if h, ok := config.(rewriter)    ; ok { handlers["rewrite"]     = noError(h.Rewrite)     }
+	if h, ok := config.(accesser)    ; ok { handlers["access"]      = noError(h.Access)      }
+	if h, ok := config.(responser)   ; ok { handlers["response"]    = noError(h.Response)    }
+	if h, ok := config.(prereader)   ; ok { handlers["preread"]     = noError(h.Preread)     }
+	if h, ok := config.(logger)      ; ok { handlers["log"]         = noError(h.Log)         }
 
-	if h, ok := config.(certificater); ok { handlers["certificate"] = h.Certificate }
-	if h, ok := config.(rewriter)    ; ok { handlers["rewrite"]     = h.Rewrite     }
-	if h, ok := config.(accesser)    ; ok { handlers["access"]      = h.Access      }
-	if h, ok := config.(responser)   ; ok { handlers["response"]    = h.Response    }
-	if h, ok := config.(prereader)   ; ok { handlers["preread"]     = h.Preread     }
-	if h, ok := config.(logger)      ; ok { handlers["log"]         = h.Log         }
+	if h, ok := config.(certificateErrer); ok { handlers["certificate"] = h.Certificate }
+	if h, ok := config.(rewriteErrer)    ; ok { handlers["rewrite"]     = h.Rewrite     }
+	if h, ok := config.(accessErrer)     ; ok { handlers["access"]      = h.Access      }
+	if h, ok := config.(responseErrer)   ; ok { handlers["response"]    = h.Response    }
+	if h, ok := config.(prereadErrer)    ; ok { handlers["preread"]     = h.Preread     }
+	if h, ok := config.(logErrer)        ; ok { handlers["log"]         = h.Log         }
 
 	return handlers
 }
 
-func (rh *rpcHandler) addInstance(instance *instanceData) {
+func noError(h func(*pdk.PDK)) PhaseFunc {
+	return func(kong *pdk.PDK) error {
+		h(kong)
+		return nil
+	}
+}
+
+func (rh *rpcHandler) addInstance(instance *instanceData) error {
 	rh.lock.Lock()
-	defer rh.lock.Unlock()
+	evicted, err := rh.makeRoom(instance)
+	if err != nil {
+		rh.lock.Unlock()
+		return err
+	}
 
 	seq := instance.configMeta.Seq
 
@@ -68,6 +111,11 @@ func (rh *rpcHandler) addInstance(instance *instanceData) {
 	instance.id = id
 
 	rh.instances[instance.id] = instance
+	rh.reportMemory()
+	rh.lock.Unlock()
+
+	closeEvicted(evicted)
+	return nil
 }
 
 // Current state of a plugin instance.  TODO: add some statistics
@@ -76,42 +124,192 @@ type InstanceStatus struct {
 	Id        int         // instance id
 	Config    interface{} // configuration data, decoded
 	StartTime int64
+	Pending   bool // still being configured (see WithAsyncConfigure)
 }
 
-// StartInstance starts a plugin instance, as required by configuration data.  More than
-// one instance can be started for a single plugin.  If the configuration changes,
-// a new instance should be started and the old one closed.
-//
-// RPC exported method
-func (rh *rpcHandler) StartInstance(config PluginConfig, status *InstanceStatus) error {
-	// TODO: check if config.Name is the one we care
+// newInstance decodes the configuration data into a new config object and
+// runs its Validate and Configure hooks, if implemented.
+func (rh *rpcHandler) newInstance(data []byte) (*instanceData, error) {
+	instance, err := rh.decodeInstance(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := configure(instance.config); err != nil {
+		return nil, err
+	}
+
+	return instance, nil
+}
+
+// decodeInstance decodes the configuration data into a new config object
+// and runs its Validate hook, if implemented.
+func (rh *rpcHandler) decodeInstance(data []byte) (*instanceData, error) {
+	if rh.maxConfigSize > 0 && len(data) > rh.maxConfigSize {
+		return nil, fmt.Errorf("config is %d bytes, over the limit of %d", len(data), rh.maxConfigSize)
+	}
+
+	hash, err := configHash(data)
+	if err != nil {
+		return nil, fmt.Errorf("decoding config: %w", err)
+	}
+
+	data, err = rh.withConfigDefaults(data)
+	if err != nil {
+		return nil, err
+	}
 
 	instanceMeta := configMetadata{}
-	if err := json.Unmarshal(config.Config, &instanceMeta); err != nil {
-		return fmt.Errorf("decoding config metadata: %w", err)
+	if err := json.Unmarshal(data, &instanceMeta); err != nil {
+		return nil, fmt.Errorf("decoding config metadata: %w", err)
 	}
 
 	instanceConfig := rh.constructor()
-	if err := json.Unmarshal(config.Config, instanceConfig); err != nil {
-		return fmt.Errorf("decoding config: %w", err)
+	decode := json.Unmarshal
+	if rh.strictDecoding {
+		decode = decodeStrict
+	}
+	if err := decode(data, instanceConfig); err != nil {
+		return nil, fmt.Errorf("decoding config: %w", err)
 	}
+	scaleDurations(reflect.ValueOf(instanceConfig))
+	applyTransforms(reflect.ValueOf(instanceConfig))
 
-	instance := instanceData{
-		startTime: time.Now(),
-		config:    instanceConfig,
+	if v, ok := instanceConfig.(validater); ok {
+		if err := v.Validate(); err != nil {
+			return nil, fmt.Errorf("validating config: %w", err)
+		}
+	}
+
+	handlers := rh.withMiddleware(getHandlers(instanceConfig))
+	return &instanceData{
+		startTime:  time.Now(),
+		config:     instanceConfig,
 		configMeta: instanceMeta,
-		handlers:  getHandlers(instanceConfig),
+		handlers:   handlers,
+		breakers:   rh.newBreakers(handlers),
+		configHash: hash,
+	}, nil
+}
+
+// configure runs the Configure hook of a config object, if implemented.
+func configure(config interface{}) error {
+	if c, ok := config.(configurer); ok {
+		if err := c.Configure(); err != nil {
+			return fmt.Errorf("configuring instance: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// preload starts an instance from the configuration given by WithPreload, if any.
+func (rh *rpcHandler) preload() error {
+	if rh.preloadConfig == nil {
+		return nil
+	}
+
+	instance, err := rh.newInstance(rh.preloadConfig)
+	if err != nil {
+		return fmt.Errorf("preloading instance: %w", err)
+	}
+
+	if err := rh.addInstance(instance); err != nil {
+		return fmt.Errorf("preloading instance: %w", err)
+	}
+	rh.lock.Lock()
+	rh.preloaded = instance
+	rh.lock.Unlock()
+
+	log.Printf("preloaded instance %d", instance.id)
+	return nil
+}
+
+// takePreloaded hands over the preloaded instance if its configuration matches
+// the given one, ignoring Kong's metadata fields.  The instance is removed from
+// its preload id, as it will be added again under the id Kong expects.
+func (rh *rpcHandler) takePreloaded(data []byte) *instanceData {
+	rh.lock.Lock()
+	defer rh.lock.Unlock()
+
+	if rh.preloaded == nil || !sameConfig(rh.preloadConfig, data) {
+		return nil
+	}
+
+	instanceMeta := configMetadata{}
+	if err := json.Unmarshal(data, &instanceMeta); err != nil {
+		return nil
+	}
+
+	instance := rh.preloaded
+	rh.preloaded = nil
+	delete(rh.instances, instance.id)
+
+	instance.configMeta = instanceMeta
+	return instance
+}
+
+// sameConfig compares two configuration blobs, skipping metadata fields
+// (those named "__xxx__").
+func sameConfig(a, b []byte) bool {
+	var ma, mb map[string]interface{}
+	if json.Unmarshal(a, &ma) != nil || json.Unmarshal(b, &mb) != nil {
+		return false
+	}
+
+	for _, m := range []map[string]interface{}{ma, mb} {
+		for k := range m {
+			if strings.HasPrefix(k, "__") {
+				delete(m, k)
+			}
+		}
+	}
+
+	return reflect.DeepEqual(ma, mb)
+}
+
+// StartInstance starts a plugin instance, as required by configuration data.  More than
+// one instance can be started for a single plugin.  If the configuration changes,
+// a new instance should be started and the old one closed.
+//
+// RPC exported method
+func (rh *rpcHandler) StartInstance(config PluginConfig, status *InstanceStatus) error {
+	// TODO: check if config.Name is the one we care
+
+	instance := rh.takePreloaded(config.Config)
+	async := false
+	if instance == nil {
+		var err error
+		if rh.asyncConfigure {
+			instance, err = rh.decodeInstance(config.Config)
+			async = true
+		} else {
+			instance, err = rh.newInstance(config.Config)
+		}
+		if err != nil {
+			return err
+		}
 	}
 
 // 	log.Printf("instance: %v", instance)
 
-	rh.addInstance(&instance)
+	if async {
+		instance.ready = make(chan struct{})
+	}
+	if err := rh.addInstance(instance); err != nil {
+		return err
+	}
+	if async {
+		go rh.configureAsync(instance)
+	}
+	rh.supersede(instance)
 
 	*status = InstanceStatus{
 		Name:      config.Name,
 		Id:        instance.id,
 		Config:    instance.config,
 		StartTime: instance.startTime.Unix(),
+		Pending:   instance.pending(),
 	}
 
 // 	log.Printf("Started instance %#v:%v", config.Name, instance.id)
@@ -119,6 +317,52 @@ func (rh *rpcHandler) StartInstance(config PluginConfig, status *InstanceStatus)
 	return nil
 }
 
+// supersede records the instance as the latest one for its plugin key, with
+// the revision following the previous one's.  If it replaces a previous
+// one, calls its OnNewConfig method with the old config, and reports the
+// change to the WithConfigAudit function.
+func (rh *rpcHandler) supersede(instance *instanceData) {
+	rh.lock.Lock()
+	key := instance.configMeta.Key
+	old, ok := rh.latestInstances[key]
+	if ok {
+		instance.revision = old.revision + 1
+	} else {
+		instance.revision = 1
+	}
+	if key != "" {
+		rh.latestInstances[key] = instance
+	}
+	rh.lock.Unlock()
+
+	if ok {
+		callOnNewConfig(instance.config, old.config)
+		rh.auditChange(old, instance)
+	}
+}
+
+// callOnNewConfig calls the optional `OnNewConfig(old Config)` method,
+// where Config is the type returned by the plugin's constructor.
+func callOnNewConfig(config, old interface{}) {
+	m := reflect.ValueOf(config).MethodByName("OnNewConfig")
+	if !m.IsValid() {
+		return
+	}
+
+	// a method declared on Config, taking a Config, works as well when
+	// the constructor returns a *Config
+	oldValue := reflect.ValueOf(old)
+	if m.Type().NumIn() == 1 && oldValue.Kind() == reflect.Ptr && !oldValue.IsNil() && oldValue.Type().Elem() == m.Type().In(0) {
+		oldValue = oldValue.Elem()
+	}
+	if m.Type().NumIn() != 1 || m.Type().NumOut() != 0 || !oldValue.Type().AssignableTo(m.Type().In(0)) {
+		log.Printf("OnNewConfig must take a single %s argument", oldValue.Type())
+		return
+	}
+
+	m.Call([]reflect.Value{oldValue})
+}
+
 // InstanceStatus returns a given resource's status (the same given when started)
 //
 // RPC exported method
@@ -135,6 +379,7 @@ func (rh *rpcHandler) InstanceStatus(id int, status *InstanceStatus) error {
 		Id:        instance.id,
 		Config:    instance.config,
 		StartTime: instance.startTime.Unix(),
+		Pending:   instance.pending(),
 	}
 
 	return nil
@@ -168,13 +413,23 @@ func (rh *rpcHandler) CloseInstance(id int, status *InstanceStatus) error {
 
 	rh.lock.Lock()
 	rh.lastCloseInstance = time.Now()
-	delete(rh.instances, id)
+	rh.forget(instance)
 	rh.lock.Unlock()
 	rh.expireInstances()
 
 	return nil
 }
 
+// forget drops an instance from the live ones, and from being the latest
+// of its plugin key, so nothing keeps its config reachable.  Must be called
+// with the lock held.
+func (rh *rpcHandler) forget(instance *instanceData) {
+	delete(rh.instances, instance.id)
+	if key := instance.configMeta.Key; key != "" && rh.latestInstances[key] == instance {
+		delete(rh.latestInstances, key)
+	}
+}
+
 func (rh *rpcHandler) expireInstances() {
 	const instanceTimeout = 60
 	expirationCutoff := time.Now().Add(time.Second * -instanceTimeout)
@@ -188,7 +443,7 @@ func (rh *rpcHandler) expireInstances() {
 	}
 
 	for _, id := range oldinstances {
-		delete(rh.instances, id)
+		rh.forget(rh.instances[id])
 	}
 	rh.lock.Unlock()
 
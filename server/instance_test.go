@@ -0,0 +1,129 @@
+package server
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type preloadConfig struct {
+	Message    string `json:"message"`
+	validated  bool
+	configured int
+}
+
+func (c *preloadConfig) Validate() error {
+	c.validated = true
+	return nil
+}
+
+func (c *preloadConfig) Configure() error {
+	c.configured++
+	return nil
+}
+
+func TestPreload(t *testing.T) {
+	rh := newRpcHandler(func() interface{} { return &preloadConfig{} }, "0.1", 1,
+		WithPreload([]byte(`{"message":"hi"}`)))
+
+	assert.NoError(t, rh.preload())
+	assert.Len(t, rh.instances, 1)
+	for _, instance := range rh.instances {
+		config := instance.config.(*preloadConfig)
+		assert.Equal(t, "hi", config.Message)
+		assert.True(t, config.validated)
+		assert.Equal(t, 1, config.configured)
+	}
+
+	var status InstanceStatus
+	err := rh.StartInstance(PluginConfig{Name: "test", Config: []byte(`{"message":"hi","__seq__":7}`)}, &status)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, status.Id)
+	assert.Len(t, rh.instances, 1)
+	assert.Equal(t, 1, status.Config.(*preloadConfig).configured)
+
+	err = rh.StartInstance(PluginConfig{Name: "test", Config: []byte(`{"message":"hi","__seq__":8}`)}, &status)
+	assert.NoError(t, err)
+	assert.Equal(t, 8, status.Id)
+	assert.Len(t, rh.instances, 2)
+}
+
+type reloadConfig struct {
+	Message string `json:"message"`
+	old     *reloadConfig
+}
+
+func (c *reloadConfig) OnNewConfig(old *reloadConfig) {
+	c.old = old
+}
+
+func TestOnNewConfig(t *testing.T) {
+	rh := newRpcHandler(func() interface{} { return &reloadConfig{} }, "0.1", 1)
+
+	var first, second, other InstanceStatus
+	assert.NoError(t, rh.StartInstance(PluginConfig{Name: "test", Config: []byte(`{"message":"one","__key__":"a","__seq__":1}`)}, &first))
+	assert.Nil(t, first.Config.(*reloadConfig).old)
+
+	assert.NoError(t, rh.StartInstance(PluginConfig{Name: "test", Config: []byte(`{"message":"two","__key__":"a","__seq__":2}`)}, &second))
+	assert.Same(t, first.Config, second.Config.(*reloadConfig).old)
+	assert.Equal(t, "one", second.Config.(*reloadConfig).old.Message)
+
+	assert.NoError(t, rh.StartInstance(PluginConfig{Name: "test", Config: []byte(`{"message":"three","__key__":"b","__seq__":3}`)}, &other))
+	assert.Nil(t, other.Config.(*reloadConfig).old)
+}
+
+type valueReloadConfig struct {
+	Message string `json:"message"`
+	olds    *[]string
+}
+
+func (c valueReloadConfig) OnNewConfig(old valueReloadConfig) {
+	*c.olds = append(*c.olds, old.Message)
+}
+
+func TestOnNewConfigValue(t *testing.T) {
+	var olds []string
+	rh := newRpcHandler(func() interface{} { return &valueReloadConfig{olds: &olds} }, "0.1", 1)
+
+	var status InstanceStatus
+	assert.NoError(t, rh.StartInstance(PluginConfig{Name: "test", Config: []byte(`{"message":"one","__key__":"a","__seq__":1}`)}, &status))
+	assert.NoError(t, rh.StartInstance(PluginConfig{Name: "test", Config: []byte(`{"message":"two","__key__":"a","__seq__":2}`)}, &status))
+	assert.Equal(t, []string{"one"}, olds)
+}
+
+func TestForgetInstances(t *testing.T) {
+	rh := newRpcHandler(func() interface{} { return &reloadConfig{} }, "0.1", 1)
+
+	var first, second InstanceStatus
+	assert.NoError(t, rh.StartInstance(PluginConfig{Name: "test", Config: []byte(`{"message":"one","__key__":"a","__seq__":1}`)}, &first))
+	assert.NoError(t, rh.StartInstance(PluginConfig{Name: "test", Config: []byte(`{"message":"two","__key__":"a","__seq__":2}`)}, &second))
+
+	// closing a replaced instance leaves its replacement as the latest
+	assert.NoError(t, rh.CloseInstance(first.Id, &first))
+	assert.Same(t, rh.instances[second.Id], rh.latestInstances["a"])
+
+	assert.NoError(t, rh.CloseInstance(second.Id, &second))
+	assert.Empty(t, rh.latestInstances)
+
+	// and so does expiring one
+	assert.NoError(t, rh.StartInstance(PluginConfig{Name: "test", Config: []byte(`{"message":"three","__key__":"b","__seq__":3}`)}, &first))
+	rh.instances[first.Id].startTime = time.Now().Add(-2 * time.Minute)
+	rh.instances[first.Id].lastEventTime = time.Now().Add(-2 * time.Minute)
+	rh.expireInstances()
+	assert.Empty(t, rh.instances)
+	assert.Empty(t, rh.latestInstances)
+}
+
+func TestMaxConfigSize(t *testing.T) {
+	rh := newRpcHandler(func() interface{} { return &preloadConfig{} }, "0.1", 1, WithMaxConfigSize(32))
+
+	var status InstanceStatus
+	assert.NoError(t, rh.StartInstance(PluginConfig{Name: "test", Config: []byte(`{"message":"hi"}`)}, &status))
+
+	big := []byte(`{"message":"` + strings.Repeat("x", 64) + `"}`)
+	err := rh.StartInstance(PluginConfig{Name: "test", Config: big}, &status)
+	assert.EqualError(t, err, "config is 78 bytes, over the limit of 32")
+	assert.Len(t, rh.instances, 1)
+}
@@ -0,0 +1,62 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A kongVersion is a Kong release number, as major, minor and patch.
+type kongVersion [3]int
+
+func parseKongVersion(s string) (kongVersion, error) {
+	var v kongVersion
+	parts := strings.Split(s, ".")
+	if len(parts) > len(v) {
+		return v, fmt.Errorf("invalid Kong version %q", s)
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("invalid Kong version %q", s)
+		}
+		v[i] = n
+	}
+
+	return v, nil
+}
+
+func (v kongVersion) less(other kongVersion) bool {
+	for i := range v {
+		if v[i] != other[i] {
+			return v[i] < other[i]
+		}
+	}
+	return false
+}
+
+func (v kongVersion) String() string {
+	return fmt.Sprintf("%d.%d.%d", v[0], v[1], v[2])
+}
+
+// Field declaration keys that older Kong releases reject, with the first
+// release accepting them.
+var keyMinVersions = map[string]kongVersion{
+	"referenceable": {2, 8, 0},
+	"encrypted":     {3, 0, 0},
+}
+
+// dropUnsupportedKeys removes from a field declaration the keys the
+// targeted Kong version (set by WithKongVersion) doesn't know about.
+func (b *schemaBuilder) dropUnsupportedKeys(decl schemaDict, path string) {
+	if b.kongVersion == (kongVersion{}) {
+		return
+	}
+
+	for key, minVersion := range keyMinVersions {
+		if _, ok := decl[key]; ok && b.kongVersion.less(minVersion) {
+			b.warnf("field %s: %s needs Kong %s, left out for %s", path, key, minVersion, b.kongVersion)
+			delete(decl, key)
+		}
+	}
+}
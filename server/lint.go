@@ -0,0 +1,64 @@
+package server
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+)
+
+// handlerWarnings looks for phase handlers and Configure hooks of the
+// config type t that won't share the state of the decoded config: those
+// promoted from an embedded type, which only see its fields, and a
+// Configure with a value receiver, whose changes are lost before the
+// handlers run.  Enabled by WithStartupChecks.
+func handlerWarnings(t reflect.Type) []string {
+	warnings := []string{}
+	elem := derefType(t)
+	for _, name := range append(methodNames[:], "Configure") {
+		method, ok := elem.MethodByName(name)
+		valueReceiver := ok
+		if !ok {
+			if method, ok = reflect.PointerTo(elem).MethodByName(name); !ok {
+				continue
+			}
+		}
+
+		if promoted(method) {
+			warnings = append(warnings, fmt.Sprintf(
+				"%s method is promoted from embedded %s, so it can't use the other fields of %s",
+				name, embeddedWith(elem, name), elem))
+		} else if name == "Configure" && valueReceiver && t.Kind() == reflect.Ptr {
+			warnings = append(warnings, fmt.Sprintf(
+				"Configure method of %s has a value receiver, so the fields it sets are lost before the handlers run", elem))
+		}
+	}
+
+	return warnings
+}
+
+// promoted tells whether a method comes from an embedded field, taken from
+// the smallest method set that has it.  The compiler generates wrappers for
+// promoted methods, which have no source file.
+func promoted(method reflect.Method) bool {
+	pc := method.Func.Pointer()
+	file, _ := runtime.FuncForPC(pc).FileLine(pc)
+	return file == "<autogenerated>"
+}
+
+// embeddedWith returns the type of the embedded field of struct type t that
+// provides the named method.
+func embeddedWith(t reflect.Type, name string) reflect.Type {
+	if t.Kind() == reflect.Struct {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.Anonymous {
+				continue
+			}
+			if _, ok := reflect.PointerTo(derefType(field.Type)).MethodByName(name); ok {
+				return field.Type
+			}
+		}
+	}
+
+	return t
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Kong/go-pdk"
+	"github.com/stretchr/testify/assert"
+)
+
+type sharedHandlers struct{}
+
+func (sharedHandlers) Log(kong *pdk.PDK) {}
+
+type misorderedConfig struct {
+	sharedHandlers
+	client *struct{}
+}
+
+func (c misorderedConfig) Configure() error {
+	c.client = &struct{}{}
+	return nil
+}
+
+func (c *misorderedConfig) Access(kong *pdk.PDK) {
+	_ = c.client
+}
+
+type orderedConfig struct {
+	client *struct{}
+}
+
+func (c *orderedConfig) Configure() error {
+	c.client = &struct{}{}
+	return nil
+}
+
+func (c orderedConfig) Access(kong *pdk.PDK) {}
+
+func TestHandlerWarnings(t *testing.T) {
+	assert.Equal(t, []string{
+		"Log method is promoted from embedded server.sharedHandlers, so it can't use the other fields of server.misorderedConfig",
+		"Configure method of server.misorderedConfig has a value receiver, so the fields it sets are lost before the handlers run",
+	}, handlerWarnings(reflect.TypeOf(&misorderedConfig{})))
+
+	assert.Empty(t, handlerWarnings(reflect.TypeOf(&orderedConfig{})))
+}
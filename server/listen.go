@@ -0,0 +1,52 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"slices"
+)
+
+// listen opens the listener Kong connects to: the socket under the Kong
+// prefix, or the address given by WithListenAddress.
+func (rh *rpcHandler) listen() (net.Listener, error) {
+	if rh.listenNetwork == "" {
+		return openSocket()
+	}
+
+	if err := rh.checkListenAddress(); err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen(rh.listenNetwork, rh.listenAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Listening on %s: %s", rh.listenNetwork, listener.Addr())
+	return listener, nil
+}
+
+// checkListenAddress refuses to listen for TCP connections from outside the
+// host, unless the address was allowed by WithAllowedListenHosts.
+func (rh *rpcHandler) checkListenAddress() error {
+	switch rh.listenNetwork {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(rh.listenAddress)
+	if err != nil {
+		return err
+	}
+
+	if host == "localhost" || slices.Contains(rh.allowedListenHosts, host) {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+
+	return fmt.Errorf("refusing to listen on non-loopback address %q", rh.listenAddress)
+}
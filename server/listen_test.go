@@ -0,0 +1,69 @@
+package server
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/Kong/go-pdk/server/kong_plugin_protocol"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestListenTCP(t *testing.T) {
+	constructor := func() interface{} { return &recordingConfig{} }
+
+	rh := newRpcHandler(constructor, "0.1", 1, WithName("test"), WithListenAddress("tcp", "127.0.0.1:0"))
+	listener, err := rh.listen()
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			servePb(conn, rh)
+		}
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	call, err := proto.Marshal(&kong_plugin_protocol.RpcCall{
+		Sequence: 1,
+		Call: &kong_plugin_protocol.RpcCall_CmdGetPluginInfo{
+			CmdGetPluginInfo: &kong_plugin_protocol.CmdGetPluginInfo{Name: "test"},
+		},
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, writePbFrame(conn, call))
+
+	data, err := readPbFrame(conn)
+	assert.NoError(t, err)
+	var ret kong_plugin_protocol.RpcReturn
+	assert.NoError(t, proto.Unmarshal(data, &ret))
+
+	info := ret.GetPluginInfo()
+	assert.Equal(t, int64(1), ret.Sequence)
+	assert.Equal(t, "test", info.Name)
+	assert.Equal(t, []string{"access", "log"}, info.Phases)
+	assert.Equal(t, "0.1", info.Version)
+	assert.Equal(t, int32(1), info.Priority)
+
+	var schema map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(info.Schema), &schema))
+	assert.Equal(t, "test", schema["name"])
+}
+
+func TestListenNonLoopback(t *testing.T) {
+	constructor := func() interface{} { return &recordingConfig{} }
+
+	rh := newRpcHandler(constructor, "0.1", 1, WithListenAddress("tcp", "0.0.0.0:0"))
+	_, err := rh.listen()
+	assert.EqualError(t, err, `refusing to listen on non-loopback address "0.0.0.0:0"`)
+
+	rh = newRpcHandler(constructor, "0.1", 1, WithListenAddress("tcp", "0.0.0.0:0"), WithAllowedListenHosts("0.0.0.0"))
+	listener, err := rh.listen()
+	assert.NoError(t, err)
+	listener.Close()
+}
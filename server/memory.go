@@ -0,0 +1,86 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"slices"
+)
+
+// Instances can report how much memory their config and derived state take,
+// in bytes, for the cap set by WithMemoryCap.
+type sizer interface{ Size() int }
+
+// MemoryMetrics can be implemented by a Metrics value to be told the total
+// memory taken by the live instances, whenever one is added.  Only instances
+// with a Size method are counted.
+type MemoryMetrics interface {
+	InstancesSize(bytes int)
+}
+
+// makeRoom records the size of a new instance and, if that takes the total
+// over the cap set by WithMemoryCap, drops the oldest instances until it
+// fits, returning them to be closed once the lock is released.  An instance
+// larger than the cap is rejected.  Must be called with the lock held.
+func (rh *rpcHandler) makeRoom(instance *instanceData) ([]*instanceData, error) {
+	if s, ok := instance.config.(sizer); ok {
+		instance.size = s.Size()
+	}
+	if rh.memoryCap <= 0 {
+		return nil, nil
+	}
+	if instance.size > rh.memoryCap {
+		return nil, fmt.Errorf("instance takes %d bytes, over the memory cap of %d", instance.size, rh.memoryCap)
+	}
+
+	total := instance.size
+	instances := make([]*instanceData, 0, len(rh.instances))
+	for _, other := range rh.instances {
+		total += other.size
+		instances = append(instances, other)
+	}
+	slices.SortFunc(instances, func(a, b *instanceData) int {
+		return a.startTime.Compare(b.startTime)
+	})
+
+	var evicted []*instanceData
+	for _, other := range instances {
+		if total <= rh.memoryCap {
+			break
+		}
+		if other.size == 0 {
+			continue
+		}
+		rh.forget(other)
+		total -= other.size
+		evicted = append(evicted, other)
+	}
+
+	return evicted, nil
+}
+
+// closeEvicted runs the Close hook of the instances makeRoom dropped.
+func closeEvicted(instances []*instanceData) {
+	for _, instance := range instances {
+		if c, ok := instance.config.(closer); ok {
+			if err := c.Close(); err != nil {
+				log.Printf("closing instance %d: %s", instance.id, err)
+			}
+		}
+		log.Printf("closed instance %d, to stay under the memory cap", instance.id)
+	}
+}
+
+// reportMemory tells the MemoryMetrics, if any, the total size of the live
+// instances.  Must be called with the lock held.
+func (rh *rpcHandler) reportMemory() {
+	m, ok := rh.metrics.(MemoryMetrics)
+	if !ok {
+		return
+	}
+
+	total := 0
+	for _, instance := range rh.instances {
+		total += instance.size
+	}
+	m.InstancesSize(total)
+}
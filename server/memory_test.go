@@ -0,0 +1,77 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type sizedConfig struct {
+	Bytes int `json:"bytes"`
+}
+
+func (c *sizedConfig) Size() int {
+	return c.Bytes
+}
+
+type memoryMetrics struct {
+	countingMetrics
+	total int
+}
+
+func (m *memoryMetrics) InstancesSize(bytes int) {
+	m.total = bytes
+}
+
+type closingSizedConfig struct {
+	sizedConfig
+	closed *[]int
+}
+
+func (c *closingSizedConfig) Close() error {
+	*c.closed = append(*c.closed, c.Bytes)
+	return nil
+}
+
+func TestMemoryCapEviction(t *testing.T) {
+	var closed []int
+	rh := newRpcHandler(func() interface{} { return &closingSizedConfig{closed: &closed} }, "0.1", 1,
+		WithMemoryCap(100))
+
+	for seq, size := range []int{40, 41, 50} {
+		var status InstanceStatus
+		config := fmt.Sprintf(`{"bytes":%d,"__key__":"k%d","__seq__":%d}`, size, seq, seq+1)
+		assert.NoError(t, rh.StartInstance(PluginConfig{Name: "test", Config: []byte(config)}, &status))
+	}
+
+	assert.Equal(t, []int{40}, closed)
+	assert.NotContains(t, rh.instances, 1)
+	assert.NotContains(t, rh.latestInstances, "k0")
+	assert.Len(t, rh.latestInstances, 2)
+}
+
+func TestMemoryCap(t *testing.T) {
+	metrics := &memoryMetrics{}
+	rh := newRpcHandler(func() interface{} { return &sizedConfig{} }, "0.1", 1,
+		WithMemoryCap(100), WithMetrics(metrics))
+
+	start := func(seq, size int) error {
+		var status InstanceStatus
+		config := fmt.Sprintf(`{"bytes":%d,"__seq__":%d}`, size, seq)
+		return rh.StartInstance(PluginConfig{Name: "test", Config: []byte(config)}, &status)
+	}
+
+	assert.NoError(t, start(1, 40))
+	assert.NoError(t, start(2, 40))
+	assert.Equal(t, 80, metrics.total)
+
+	assert.NoError(t, start(3, 50))
+	assert.NotContains(t, rh.instances, 1)
+	assert.Contains(t, rh.instances, 2)
+	assert.Contains(t, rh.instances, 3)
+	assert.Equal(t, 90, metrics.total)
+
+	assert.EqualError(t, start(4, 150), "instance takes 150 bytes, over the memory cap of 100")
+	assert.Len(t, rh.instances, 2)
+}
@@ -0,0 +1,10 @@
+package server
+
+// Metrics receives measurements from the plugin server, to be exported to
+// a monitoring system.  Its methods can be called concurrently.
+type Metrics interface {
+	// HandlerError counts a failed phase handler.  kind tells how it
+	// failed: "panic", "timeout", "error" (for a returned error) or
+	// "short_circuit" (for an event skipped by WithTimeoutBreaker).
+	HandlerError(phase, kind string)
+}
@@ -0,0 +1,31 @@
+package server
+
+import (
+	"github.com/Kong/go-pdk"
+)
+
+// A PhaseFunc handles a single phase (event) of a request.
+// Phase methods without a return value are wrapped to return nil.
+type PhaseFunc func(kong *pdk.PDK) error
+
+// A Middleware wraps the handler of a phase, to add behaviour common to
+// every phase.  It should call next to run the wrapped handler.
+type Middleware func(next PhaseFunc) PhaseFunc
+
+// withMiddleware wraps each handler with the middleware given by
+// WithMiddleware.  The first middleware is the outermost one.
+func (rh *rpcHandler) withMiddleware(handlers map[string]PhaseFunc) map[string]PhaseFunc {
+	if len(rh.middleware) == 0 {
+		return handlers
+	}
+
+	for name, h := range handlers {
+		f := h
+		for i := len(rh.middleware) - 1; i >= 0; i-- {
+			f = rh.middleware[i](f)
+		}
+		handlers[name] = f
+	}
+
+	return handlers
+}
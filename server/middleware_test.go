@@ -0,0 +1,66 @@
+package server
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	"github.com/Kong/go-pdk"
+	"github.com/Kong/go-pdk/server/kong_plugin_protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingConfig struct {
+	calls *[]string
+}
+
+func (c recordingConfig) Access(kong *pdk.PDK) {
+	*c.calls = append(*c.calls, "access")
+}
+
+func (c recordingConfig) Log(kong *pdk.PDK) {
+	*c.calls = append(*c.calls, "log")
+}
+
+// dispatch runs an event as if Kong had sent it, discarding the closing frame.
+func dispatch(t *testing.T, rh *rpcHandler, instanceId int, eventName string) error {
+	kong, plugin := net.Pipe()
+	defer kong.Close()
+	defer plugin.Close()
+
+	go func() {
+		_, _ = io.Copy(io.Discard, kong)
+	}()
+
+	return handlePbEvent(rh, plugin, &kong_plugin_protocol.CmdHandleEvent{
+		InstanceId: int32(instanceId),
+		EventName:  eventName,
+	})
+}
+
+func TestMiddleware(t *testing.T) {
+	calls := []string{}
+	record := func(name string) Middleware {
+		return func(next PhaseFunc) PhaseFunc {
+			return func(kong *pdk.PDK) error {
+				calls = append(calls, name+" before")
+				err := next(kong)
+				calls = append(calls, name+" after")
+				return err
+			}
+		}
+	}
+
+	rh := newRpcHandler(func() interface{} { return &recordingConfig{calls: &calls} }, "0.1", 1,
+		WithMiddleware(record("outer"), record("inner")))
+
+	var status InstanceStatus
+	assert.NoError(t, rh.StartInstance(PluginConfig{Name: "test", Config: []byte(`{"__seq__":1}`)}, &status))
+
+	assert.NoError(t, dispatch(t, rh, status.Id, "access"))
+	assert.NoError(t, dispatch(t, rh, status.Id, "log"))
+	assert.Equal(t, []string{
+		"outer before", "inner before", "access", "inner after", "outer after",
+		"outer before", "inner before", "log", "inner after", "outer after",
+	}, calls)
+}
@@ -0,0 +1,283 @@
+package server
+
+import (
+	"log"
+	"time"
+)
+
+// A ServerOption customizes the embedded plugin server.
+// Options are passed as trailing arguments to StartServer.
+type ServerOption func(*rpcHandler)
+
+// WithPreload starts an instance from the given configuration data (a JSON
+// object, as sent by Kong) before the server starts listening, so any work
+// done by the Validate and Configure hooks is already done when Kong asks
+// for an instance with the same configuration.
+func WithPreload(config []byte) ServerOption {
+	return func(rh *rpcHandler) {
+		rh.preloadConfig = config
+	}
+}
+
+// WithDerivedLenMax makes string fields restricted by a `one_of` tag declare
+// a `len_max` equal to their longest allowed value, unless one is given.
+func WithDerivedLenMax() ServerOption {
+	return func(rh *rpcHandler) {
+		rh.schemaOptions.deriveLenMax = true
+	}
+}
+
+// WithoutSchema skips schema generation, for plugins with no user-facing
+// configuration.  Kong receives a schema with an empty list of fields.
+func WithoutSchema() ServerOption {
+	return func(rh *rpcHandler) {
+		rh.noSchema = true
+	}
+}
+
+// WithName sets the plugin name reported to Kong, instead of deriving it
+// from the name of the executable.
+func WithName(name string) ServerOption {
+	return func(rh *rpcHandler) {
+		rh.name = name
+	}
+}
+
+// WithRecordDefaults gives a default to every record whose fields all have
+// defaults, made of those values.
+func WithRecordDefaults() ServerOption {
+	return func(rh *rpcHandler) {
+		rh.schemaOptions.recordDefaults = true
+	}
+}
+
+// WithPprof serves the runtime profiling data from net/http/pprof on the
+// given port, listening only on the loopback interface.
+func WithPprof(port int) ServerOption {
+	return func(rh *rpcHandler) {
+		rh.pprofAddr = loopbackAddr(port)
+	}
+}
+
+// WithMiddleware runs every phase handler through the given middleware,
+// applied in order: the first one sees the call first.
+func WithMiddleware(middleware ...Middleware) ServerOption {
+	return func(rh *rpcHandler) {
+		rh.middleware = append(rh.middleware, middleware...)
+	}
+}
+
+// WithOnShutdown sets a function to run when the server stops, after the
+// Close hook of every instance.
+func WithOnShutdown(f func() error) ServerOption {
+	return func(rh *rpcHandler) {
+		rh.onShutdown = f
+	}
+}
+
+// WithShutdownTimeout bounds the time spent running the Close hooks and the
+// WithOnShutdown function when the server stops.  Defaults to 5 seconds.
+func WithShutdownTimeout(timeout time.Duration) ServerOption {
+	return func(rh *rpcHandler) {
+		rh.shutdownTimeout = timeout
+	}
+}
+
+// WithProtocolPhases reports, besides the list of handled events, which of
+// them apply to each Kong subsystem (http and stream), so a plugin working
+// on both gets the right events enabled on each.
+func WithProtocolPhases() ServerOption {
+	return func(rh *rpcHandler) {
+		rh.protocolPhases = true
+	}
+}
+
+// WithMaxConfigSize rejects instances whose configuration data is larger
+// than the given number of bytes, before decoding it.
+func WithMaxConfigSize(size int) ServerOption {
+	return func(rh *rpcHandler) {
+		rh.maxConfigSize = size
+	}
+}
+
+// WithPhaseTimeout limits the time a phase handler can take.  When exceeded,
+// the connection with Kong is dropped, failing the request.
+func WithPhaseTimeout(timeout time.Duration) ServerOption {
+	return func(rh *rpcHandler) {
+		rh.phaseTimeout = timeout
+	}
+}
+
+// WithMetrics reports the server's measurements to m.
+func WithMetrics(m Metrics) ServerOption {
+	return func(rh *rpcHandler) {
+		rh.metrics = m
+	}
+}
+
+// WithKongVersion targets the schema to the given Kong release (as in
+// "2.8" or "3.4.1"), leaving out declarations it would reject.
+func WithKongVersion(version string) ServerOption {
+	return func(rh *rpcHandler) {
+		v, err := parseKongVersion(version)
+		if err != nil {
+			log.Printf("ignoring WithKongVersion: %s", err)
+			return
+		}
+		rh.schemaOptions.kongVersion = v
+	}
+}
+
+// WithListenAddress makes the server listen on the given network and
+// address (as accepted by net.Listen) instead of the socket under the Kong
+// prefix, for instance to debug a plugin running in a container.  TCP
+// addresses must be on the loopback interface, unless their host is given
+// to WithAllowedListenHosts.
+func WithListenAddress(network, address string) ServerOption {
+	return func(rh *rpcHandler) {
+		rh.listenNetwork = network
+		rh.listenAddress = address
+	}
+}
+
+// WithAllowedListenHosts allows WithListenAddress to use the given
+// non-loopback hosts, exposing the plugin server to other machines.
+func WithAllowedListenHosts(hosts ...string) ServerOption {
+	return func(rh *rpcHandler) {
+		rh.allowedListenHosts = append(rh.allowedListenHosts, hosts...)
+	}
+}
+
+// WithTimeoutBreaker short-circuits the handler of a phase for the cooldown
+// time after it times out (see WithPhaseTimeout) threshold times in a row
+// on the same instance, handling the phase's events according to policy
+// meanwhile.
+func WithTimeoutBreaker(threshold int, cooldown time.Duration, policy DegradePolicy) ServerOption {
+	return func(rh *rpcHandler) {
+		rh.breakerThreshold = threshold
+		rh.breakerCooldown = cooldown
+		rh.breakerPolicy = policy
+	}
+}
+
+// WithAsyncConfigure runs the Configure hook of new instances in the
+// background, so Kong doesn't time out waiting for expensive setup.  The
+// instance is reported as pending meanwhile, with its events handled
+// according to policy.  If Configure fails, the error is logged and the
+// instance closed.  Preloaded instances are always configured beforehand.
+func WithAsyncConfigure(policy PendingPolicy) ServerOption {
+	return func(rh *rpcHandler) {
+		rh.asyncConfigure = true
+		rh.pendingPolicy = policy
+	}
+}
+
+// WithStrictSchema refuses to serve a plugin whose config type has fields
+// tagged with both between and one_of, so authors pick one of them.
+func WithStrictSchema() ServerOption {
+	return func(rh *rpcHandler) {
+		rh.schemaOptions.strict = true
+	}
+}
+
+// WithConfigAudit calls f when Kong replaces the configuration of a plugin
+// (as identified by its key) with a new one, telling which fields changed.
+func WithConfigAudit(f func(ConfigChange)) ServerOption {
+	return func(rh *rpcHandler) {
+		rh.onConfigChange = f
+	}
+}
+
+// WithWorkerPool handles events on a fixed number of goroutines, instead of
+// one for each connection, bounding the memory and scheduling overhead under
+// heavy load.  Each connection is still read on a goroutine of its own, so
+// long-lived connections don't hold workers between events; events beyond
+// what the pool can take wait for a worker.  On shutdown, the pool stops
+// once the events it's handling are done.
+func WithWorkerPool(size int) ServerOption {
+	return func(rh *rpcHandler) {
+		rh.poolSize = size
+	}
+}
+
+// WithStartupChecks logs a warning for each phase handler or Configure hook
+// that won't see the state of the decoded config: one promoted from an
+// embedded type, or a Configure method with a value receiver (its changes
+// to the config are lost).
+func WithStartupChecks() ServerOption {
+	return func(rh *rpcHandler) {
+		rh.startupChecks = true
+	}
+}
+
+// WithMemoryCap limits the total memory taken by the live instances to the
+// given number of bytes, as reported by the Size method of their config
+// (only those having one are counted).  When a new instance goes over it,
+// the oldest ones are closed to make room, running their Close method if
+// they have one; one larger than the cap can't be started.  With
+// WithAsyncConfigure, Size is called before Configure.
+func WithMemoryCap(bytes int) ServerOption {
+	return func(rh *rpcHandler) {
+		rh.memoryCap = bytes
+	}
+}
+
+// WithDefaultsFile reads a JSON object from the given file when the server
+// starts, whose values fill the fields of instance configurations that Kong
+// leaves unset (missing or null), descending into records.
+func WithDefaultsFile(path string) ServerOption {
+	return func(rh *rpcHandler) {
+		rh.defaultsFile = path
+	}
+}
+
+// WithProtocols sets the default protocols the plugin applies to, instead
+// of deriving them from the handled events.
+func WithProtocols(protocols ...string) ServerOption {
+	return func(rh *rpcHandler) {
+		rh.protocols = protocols
+	}
+}
+
+// WithStrictDecoding rejects instances whose configuration data has keys
+// matching no field of the config type, as when Kong still has a value for
+// a field since removed, instead of silently ignoring them.
+func WithStrictDecoding() ServerOption {
+	return func(rh *rpcHandler) {
+		rh.strictDecoding = true
+	}
+}
+
+// WithOneOfDefaults gives fields restricted by one_of (including those of
+// an Enum type) the first allowed value as their default, unless one is
+// given.
+func WithOneOfDefaults() ServerOption {
+	return func(rh *rpcHandler) {
+		rh.schemaOptions.oneOfDefaults = true
+	}
+}
+
+// WithNullablePointers marks optional pointer fields (those not tagged
+// `kong:"required=true"`) with "nullable": true in the schema returned by
+// Describe, telling that they may be null, and are then left nil in the
+// decoded config, as when absent.  Kong rejects the key, and accepts null
+// for any field that isn't required, so it's left out of the schema sent
+// to Kong (and of the -dump output).
+func WithNullablePointers() ServerOption {
+	return func(rh *rpcHandler) {
+		rh.nullablePointers = true
+	}
+}
+
+// WithPresetFile reads a JSON object from the given file when the server
+// starts, naming sets of field attributes that fields tagged with
+// `kong:"preset_ref=name"` take, as in
+//
+//	{"standard_timeout": {"type": "integer", "default": 30000, "between": [1, 60000]}}
+//
+// so an organization can share common validators across plugins.
+func WithPresetFile(path string) ServerOption {
+	return func(rh *rpcHandler) {
+		rh.presetFile = path
+	}
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+)
+
+// Keys of field declarations in the order they are serialized: the type
+// first, then its validators, then nested declarations.  Other keys follow,
+// sorted.  decK diffs schemas textually, so a stable order matters.
+var keyOrder = []string{
+	"name", "type", "required", "nullable", "default", "description",
+	"referenceable", "encrypted",
+	"eq", "one_of", "between", "len_min", "len_max", "match", "match_all",
+	"elements", "keys", "values", "fields",
+	"entity_checks",
+}
+
+// MarshalJSON encodes the dict with its keys in canonical order.
+func (d schemaDict) MarshalJSON() ([]byte, error) {
+	keys := make([]string, 0, len(d))
+	for key := range d {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		i, j := slices.Index(keyOrder, a), slices.Index(keyOrder, b)
+		switch {
+		case i >= 0 && j >= 0:
+			return i - j
+		case i >= 0:
+			return -1
+		case j >= 0:
+			return 1
+		}
+		return bytes.Compare([]byte(a), []byte(b))
+	})
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(d[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,36 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyOrder(t *testing.T) {
+	type Config struct {
+		Mode  string   `json:"mode" kong:"one_of=a;b,default=a,required=true,len_max=1"`
+		Hosts []string `json:"hosts" kong:"required=true"`
+	}
+
+	const expected = `{"name":"test","fields":[` +
+		`{"protocols":{"type":"set","required":true,"default":["http","https","grpc","grpcs","tcp","tls","udp"],` +
+		`"elements":{"type":"string","one_of":["http","https","grpc","grpcs","tcp","tls","udp"]}}},` +
+		`{"config":{"type":"record","fields":[` +
+		`{"mode":{"type":"string","required":true,"default":"a","one_of":["a","b"],"len_max":1}},` +
+		`{"hosts":{"type":"array","required":true,"elements":{"type":"string"}}}]}}]}`
+
+	rh := newRpcHandler(func() interface{} { return &Config{} }, "0.1", 1, WithName("test"))
+	schema, err := rh.getSchema("test")
+	assert.NoError(t, err)
+
+	encoded, err := json.Marshal(schema)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(encoded))
+
+	// as dumped by the -dump flag
+	var buf bytes.Buffer
+	assert.NoError(t, writeInfo(&buf, rh, "/tmp/test.socket"))
+	assert.Contains(t, buf.String(), `"Schema":`+expected)
+}
@@ -1,7 +1,10 @@
 package server
 
 import (
+	"encoding/json"
 	"flag"
+	"fmt"
+	"io"
 	"log"
 	"net"
 	"os"
@@ -70,32 +73,52 @@ func openSocket() (listener net.Listener, err error) {
 type serverInfo struct {
 	Protocol   string
 	SocketPath string
-	Plugins    []pluginInfo
+	Plugins    []dumpedPlugin
+}
+
+// dumpedPlugin is a pluginInfo whose schema is encoded beforehand by
+// encoding/json, which keeps the key order of schemaDict.MarshalJSON.
+type dumpedPlugin struct {
+	pluginInfo
+	Schema codec.Raw
 }
 
 func dumpInfo(rh *rpcHandler) {
-	info, err := rh.getInfo()
+	socketPath, err := getSocketPath()
 	if err != nil {
-		log.Printf("getting plugin info: %s", err)
+		log.Printf("getting Socket path: %s", err)
 		return
 	}
 
-	socketPath, err := getSocketPath()
+	err = writeInfo(os.Stdout, rh, socketPath)
 	if err != nil {
-		log.Printf("getting Socket path: %s", err)
-		return
+		log.Printf("%s", err)
+	}
+	os.Stdout.WriteString("\n")
+}
+
+func writeInfo(w io.Writer, rh *rpcHandler, socketPath string) error {
+	info, err := rh.getInfo()
+	if err != nil {
+		return fmt.Errorf("getting plugin info: %w", err)
+	}
+
+	schema, err := json.Marshal(info.Schema)
+	if err != nil {
+		return fmt.Errorf("encoding plugin schema: %w", err)
 	}
 
-	var handle codec.JsonHandle
-	enc := codec.NewEncoder(os.Stdout, &handle)
+	handle := codec.JsonHandle{}
+	handle.Raw = true
+	enc := codec.NewEncoder(w, &handle)
 	err = enc.Encode(serverInfo{
 		Protocol:   "ProtoBuf:1",
 		SocketPath: socketPath,
-		Plugins:    []pluginInfo{info},
+		Plugins:    []dumpedPlugin{{pluginInfo: info, Schema: schema}},
 	})
 	if err != nil {
-		log.Printf("encoding plugin info: %s", err)
+		return fmt.Errorf("encoding plugin info: %w", err)
 	}
-	os.Stdout.WriteString("\n")
-}
 
+	return nil
+}
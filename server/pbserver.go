@@ -2,10 +2,15 @@ package server
 
 import (
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
 
 	"github.com/Kong/go-pdk"
 	"github.com/Kong/go-pdk/server/kong_plugin_protocol"
@@ -13,6 +18,14 @@ import (
 )
 
 func servePb(conn net.Conn, rh *rpcHandler) {
+	if err := serveConn(conn, rh); err != nil {
+		log.Print(err)
+	}
+}
+
+// serveConn answers the RPC calls coming from conn until it fails, and
+// closes it.  Returns the error that stopped it.
+func serveConn(conn net.Conn, rh *rpcHandler) error {
 	var err error
 	var d, rd []byte
 	for {
@@ -33,9 +46,7 @@ func servePb(conn net.Conn, rh *rpcHandler) {
 	}
 
 	conn.Close()
-	if err != nil {
-		log.Print(err)
-	}
+	return err
 }
 
 func readPbFrame(conn net.Conn) (data []byte, err error) {
@@ -62,7 +73,9 @@ func writePbFrame(conn net.Conn, data []byte) (err error) {
 		return
 	}
 
-	_, err = conn.Write(data)
+	if len > 0 {
+		_, err = conn.Write(data)
+	}
 
 	return
 }
@@ -102,7 +115,30 @@ func handlePbCmd(rh *rpcHandler, conn net.Conn, m *kong_plugin_protocol.RpcCall)
 		// 		log.Printf("GetPluginNames: %v", c)
 
 	case *kong_plugin_protocol.RpcCall_CmdGetPluginInfo:
-		// 		log.Printf("GetPluginInfo: %v", c)
+		var info pluginInfo
+		info, err = rh.getInfo()
+		if err != nil {
+			return
+		}
+
+		var schema []byte
+		schema, err = json.Marshal(info.Schema)
+		if err != nil {
+			return
+		}
+
+		rm = &kong_plugin_protocol.RpcReturn{
+			Sequence: m.Sequence,
+			Return: &kong_plugin_protocol.RpcReturn_PluginInfo{
+				PluginInfo: &kong_plugin_protocol.PluginInfo{
+					Name:     info.Name,
+					Phases:   info.Phases,
+					Version:  info.Version,
+					Priority: int32(info.Priority),
+					Schema:   string(schema),
+				},
+			},
+		}
 
 	case *kong_plugin_protocol.RpcCall_CmdStartInstance:
 		config := PluginConfig{
@@ -144,7 +180,7 @@ func handlePbCmd(rh *rpcHandler, conn net.Conn, m *kong_plugin_protocol.RpcCall)
 		}
 
 	case *kong_plugin_protocol.RpcCall_CmdHandleEvent:
-		err = handlePbEvent(rh, conn, c.CmdHandleEvent)
+		err = rh.dispatch(func() error { return handlePbEvent(rh, conn, c.CmdHandleEvent) })
 		rm = &kong_plugin_protocol.RpcReturn{
 			Sequence: m.Sequence,
 		}
@@ -169,34 +205,77 @@ func handlePbEvent(rh *rpcHandler, conn net.Conn, e *kong_plugin_protocol.CmdHan
 		return fmt.Errorf("undefined method %s", e.EventName)
 	}
 
+	run, err := rh.awaitReady(instance)
+	if err != nil {
+		return err
+	}
+	if !run {
+		return writePbFrame(conn, []byte{})
+	}
+
 	pdk := pdk.Init(conn)
 
-	h(pdk)
+	if err := rh.handleEvent(e.EventName, h, instance.breakers[e.EventName], pdk); err != nil {
+		return err
+	}
 	return writePbFrame(conn, []byte{})
 }
 
 // Start the embedded plugin server, ProtoBuf version.
 // Handles CLI flags, and returns immediately if appropriate.
-// Otherwise, returns only if the server is stopped.
-func StartServer(constructor func() interface{}, version string, priority int) error {
+// Otherwise, returns only if the server is stopped, which happens on
+// SIGINT or SIGTERM, after closing every instance.
+// Optional behaviour can be enabled by passing ServerOption values.
+func StartServer(constructor func() interface{}, version string, priority int, opts ...ServerOption) error {
 	parseCli()
 
-	rh := newRpcHandler(constructor, version, priority)
+	rh, err := buildRpcHandler(constructor, version, priority, opts...)
+	if err != nil {
+		return err
+	}
 
 	if *dump {
 		dumpInfo(rh)
 		return nil
 	}
 
-	listener, err := openSocket()
+	if err := rh.preload(); err != nil {
+		return err
+	}
+
+	pprofListener, err := rh.startPprof()
+	if err != nil {
+		return err
+	}
+	if pprofListener != nil {
+		defer pprofListener.Close()
+	}
+
+	listener, err := rh.listen()
 	if err != nil {
 		return err
 	}
 	defer listener.Close()
 
+	if rh.poolSize > 0 {
+		rh.pool = newWorkerPool(rh.poolSize)
+	}
+
+	var stopping atomic.Bool
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-stop
+		stopping.Store(true)
+		listener.Close()
+	}()
+
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
+			if stopping.Load() {
+				return rh.shutdown()
+			}
 			log.Fatal(err)
 		}
 
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"errors"
+	"sync"
+)
+
+// workerPool runs tasks on a fixed number of goroutines.  Tasks wait in a
+// queue as long as the pool, so once every worker is busy and the queue is
+// full, submitting blocks until the load comes down.
+type workerPool struct {
+	queue   chan func()
+	wg      sync.WaitGroup
+	lock    sync.RWMutex // held while submitting, so stop can't close queue under a sender
+	stopped bool
+}
+
+func newWorkerPool(size int) *workerPool {
+	p := &workerPool{queue: make(chan func(), size)}
+	p.wg.Add(size)
+	for i := 0; i < size; i++ {
+		go p.work()
+	}
+	return p
+}
+
+func (p *workerPool) work() {
+	defer p.wg.Done()
+	for task := range p.queue {
+		task()
+	}
+}
+
+// submit queues a task, blocking while the queue is full.  Returns false,
+// without running the task, once the pool is stopped.
+func (p *workerPool) submit(task func()) bool {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	if p.stopped {
+		return false
+	}
+
+	p.queue <- task
+	return true
+}
+
+// stop waits for the queued tasks to finish.  No task can be submitted after.
+func (p *workerPool) stop() {
+	p.lock.Lock()
+	if !p.stopped {
+		p.stopped = true
+		close(p.queue)
+	}
+	p.lock.Unlock()
+	p.wg.Wait()
+}
+
+var errPoolStopped = errors.New("server is shutting down")
+
+// dispatch runs an event handling task on the worker pool set by
+// WithWorkerPool, or right away if there's none, and returns its error.
+// Connections are served on goroutines of their own either way: only the
+// events they carry wait for a worker.
+func (rh *rpcHandler) dispatch(task func() error) error {
+	if rh.pool == nil {
+		return task()
+	}
+
+	done := make(chan error, 1)
+	if !rh.pool.submit(func() { done <- task() }) {
+		return errPoolStopped
+	}
+	return <-done
+}
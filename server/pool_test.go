@@ -0,0 +1,100 @@
+package server
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Kong/go-pdk"
+	"github.com/stretchr/testify/assert"
+)
+
+type peakConfig struct {
+	running, peak *atomic.Int32
+}
+
+func (c peakConfig) Access(kong *pdk.PDK) {
+	n := c.running.Add(1)
+	defer c.running.Add(-1)
+	for p := c.peak.Load(); n > p && !c.peak.CompareAndSwap(p, n); p = c.peak.Load() {
+	}
+	time.Sleep(time.Millisecond)
+}
+
+func TestWorkerPoolLoad(t *testing.T) {
+	const workers, conns, events = 4, 50, 5
+
+	var running, peak atomic.Int32
+	rh := newRpcHandler(func() interface{} { return &peakConfig{running: &running, peak: &peak} }, "0.1", 1,
+		WithWorkerPool(workers))
+	rh.pool = newWorkerPool(rh.poolSize)
+
+	var status InstanceStatus
+	assert.NoError(t, rh.StartInstance(PluginConfig{Name: "test", Config: []byte(`{"__seq__":1}`)}, &status))
+
+	// Every connection stays open across its events, as Kong's do, and
+	// there are many more of them than workers.
+	var wg sync.WaitGroup
+	for i := 0; i < conns; i++ {
+		k := newFakeKong(rh, nil)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer k.Close()
+			for j := 0; j < events; j++ {
+				assert.NoError(t, k.HandleEvent(int32(status.Id), "access"))
+			}
+		}()
+	}
+
+	wg.Wait()
+	assert.LessOrEqual(t, peak.Load(), int32(workers))
+	assert.Positive(t, peak.Load())
+
+	assert.NoError(t, rh.shutdown())
+	assert.ErrorIs(t, rh.dispatch(func() error { return nil }), errPoolStopped)
+}
+
+func BenchmarkDispatch(b *testing.B) {
+	task := func() error {
+		sum := 0
+		for i := 0; i < 100; i++ {
+			sum += i
+		}
+		_ = sum
+		return nil
+	}
+
+	// what the server does without a pool: a goroutine for each event
+	b.Run("goroutine", func(b *testing.B) {
+		var wg sync.WaitGroup
+		wg.Add(b.N)
+		for i := 0; i < b.N; i++ {
+			go func() {
+				defer wg.Done()
+				_ = task()
+			}()
+		}
+		wg.Wait()
+	})
+
+	b.Run("direct", func(b *testing.B) {
+		rh := &rpcHandler{}
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				_ = rh.dispatch(task)
+			}
+		})
+	})
+
+	b.Run("pool", func(b *testing.B) {
+		rh := &rpcHandler{pool: newWorkerPool(8)}
+		defer rh.pool.stop()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				_ = rh.dispatch(task)
+			}
+		})
+	})
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strconv"
+)
+
+// startPprof serves the net/http/pprof handlers on the loopback port given by
+// WithPprof.  Returns a nil listener if it's not enabled.
+func (rh *rpcHandler) startPprof() (net.Listener, error) {
+	if rh.pprofAddr == "" {
+		return nil, nil
+	}
+
+	listener, err := net.Listen("tcp", rh.pprofAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	go func() {
+		err := http.Serve(listener, mux)
+		if err != nil {
+			log.Printf("pprof server: %s", err)
+		}
+	}()
+
+	log.Printf("Serving pprof on: http://%s/debug/pprof/", listener.Addr())
+	return listener, nil
+}
+
+func loopbackAddr(port int) string {
+	return net.JoinHostPort("127.0.0.1", strconv.Itoa(port))
+}
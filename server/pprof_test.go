@@ -0,0 +1,29 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPprof(t *testing.T) {
+	type Config struct{}
+	constructor := func() interface{} { return &Config{} }
+
+	rh := newRpcHandler(constructor, "0.1", 1)
+	listener, err := rh.startPprof()
+	assert.NoError(t, err)
+	assert.Nil(t, listener)
+
+	rh = newRpcHandler(constructor, "0.1", 1, WithPprof(0))
+	listener, err = rh.startPprof()
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	res, err := http.Get("http://" + listener.Addr().String() + "/debug/pprof/")
+	assert.NoError(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Contains(t, listener.Addr().String(), "127.0.0.1:")
+}
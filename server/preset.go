@@ -0,0 +1,63 @@
+package server
+
+import (
+	"maps"
+	"sync"
+)
+
+var presets = struct {
+	lock   sync.RWMutex
+	fields map[string][]schemaDict
+}{
+	fields: map[string][]schemaDict{},
+}
+
+// RegisterPreset names a set of field declarations that a field tagged
+// `kong:"preset=name"` expands into: its declaration becomes a record of
+// those fields, whatever its Go type (usually a struct or map decoding
+// them).  Each declaration is a Kong schema field, a map from the field
+// name to its attributes, as in
+//
+//	server.RegisterPreset("ratelimit",
+//		map[string]interface{}{"limit": map[string]interface{}{"type": "integer", "default": 100}},
+//		map[string]interface{}{"window": map[string]interface{}{"type": "integer", "default": 60}})
+//
+// Registering a name again replaces its fields.
+func RegisterPreset(name string, fields ...map[string]interface{}) {
+	decls := make([]schemaDict, len(fields))
+	for i, field := range fields {
+		decls[i] = schemaDict{}
+		for name, attrs := range field {
+			if m, ok := attrs.(map[string]interface{}); ok {
+				attrs = schemaDict(m)
+			}
+			decls[i][name] = attrs
+		}
+	}
+
+	presets.lock.Lock()
+	defer presets.lock.Unlock()
+	presets.fields[name] = decls
+}
+
+// presetDecl returns the record declaration of a registered preset.
+func presetDecl(name string) (schemaDict, bool) {
+	presets.lock.RLock()
+	defer presets.lock.RUnlock()
+	fields, ok := presets.fields[name]
+	if !ok {
+		return nil, false
+	}
+
+	decls := make([]schemaDict, len(fields))
+	for i, field := range fields {
+		decls[i] = schemaDict{}
+		for name, attrs := range field {
+			if m, ok := attrs.(schemaDict); ok {
+				attrs = maps.Clone(m)
+			}
+			decls[i][name] = attrs
+		}
+	}
+	return schemaDict{"type": "record", "fields": decls}, true
+}
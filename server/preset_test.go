@@ -0,0 +1,35 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreset(t *testing.T) {
+	RegisterPreset("ratelimit",
+		map[string]interface{}{"limit": map[string]interface{}{"type": "integer", "default": 100}},
+		map[string]interface{}{"window": map[string]interface{}{"type": "integer", "default": 60}})
+	t.Cleanup(func() { delete(presets.fields, "ratelimit") })
+
+	type Config struct {
+		Limits  map[string]int `json:"limits" kong:"preset=ratelimit,required=true"`
+		Unknown map[string]int `json:"unknown" kong:"preset=nope"`
+	}
+
+	b := &schemaBuilder{}
+	schema := b.getSchemaDict(reflect.TypeOf(Config{}), "config")
+	assert.Equal(t, []schemaDict{
+		{"limits": schemaDict{
+			"type": "record",
+			"fields": []schemaDict{
+				{"limit": schemaDict{"type": "integer", "default": 100}},
+				{"window": schemaDict{"type": "integer", "default": 60}},
+			},
+			"required": true,
+		}},
+		{"unknown": schemaDict{"type": "map", "keys": schemaDict{"type": "string"}, "values": schemaDict{"type": "integer"}}},
+	}, schema["fields"])
+	assert.Equal(t, []string{`field config.unknown: unknown preset "nope"`}, b.warnings)
+}
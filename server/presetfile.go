@@ -0,0 +1,173 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+)
+
+// loadPresetFile reads the file given by WithPresetFile, if any: a JSON
+// object mapping each preset name to the attributes it gives a field.
+func (rh *rpcHandler) loadPresetFile() error {
+	if rh.presetFile == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(rh.presetFile)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var fragments map[string]map[string]interface{}
+	if err := decoder.Decode(&fragments); err != nil {
+		return fmt.Errorf("decoding %s: %w", rh.presetFile, err)
+	}
+
+	rh.schemaOptions.presetRefs = map[string]schemaDict{}
+	for name, fragment := range fragments {
+		rh.schemaOptions.presetRefs[name] = declValue(fragment).(schemaDict)
+	}
+	return nil
+}
+
+// declValue converts a value decoded from JSON into the form the schema
+// builder gives declarations: objects are schemaDicts, whole numbers are
+// ints, and lists of strings or numbers are typed slices.
+func declValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		d := schemaDict{}
+		for key, value := range v {
+			d[key] = declValue(value)
+		}
+		return d
+
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return int(n)
+		}
+		f, _ := v.Float64()
+		return f
+
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, value := range v {
+			values[i] = declValue(value)
+		}
+		return typedSlice(values)
+	}
+
+	return v
+}
+
+// typedSlice returns a []string, []int or []float64 with the values, if
+// they all fit one, as for one_of or between lists.  Lists mixing ints and
+// floats are []float64.  Others, like lists of field declarations, are
+// left as they are.
+func typedSlice(values []interface{}) interface{} {
+	if len(values) == 0 {
+		return values
+	}
+
+	switch values[0].(type) {
+	case string:
+		list := make([]string, len(values))
+		for i, value := range values {
+			s, ok := value.(string)
+			if !ok {
+				return values
+			}
+			list[i] = s
+		}
+		return list
+
+	case int, float64:
+		ints := make([]int, 0, len(values))
+		floats := make([]float64, len(values))
+		for i, value := range values {
+			switch n := value.(type) {
+			case int:
+				ints = append(ints, n)
+				floats[i] = float64(n)
+			case float64:
+				floats[i] = n
+			default:
+				return values
+			}
+		}
+		if len(ints) == len(values) {
+			return ints
+		}
+		return floats
+
+	case schemaDict:
+		list := make([]schemaDict, len(values))
+		for i, value := range values {
+			d, ok := value.(schemaDict)
+			if !ok {
+				return values
+			}
+			list[i] = d
+		}
+		return list
+	}
+
+	return values
+}
+
+// cloneDecl copies a declaration value deeply enough for the copy to be
+// changed without affecting the original.
+func cloneDecl(v interface{}) interface{} {
+	switch v := v.(type) {
+	case schemaDict:
+		d := make(schemaDict, len(v))
+		for key, value := range v {
+			d[key] = cloneDecl(value)
+		}
+		return d
+	case []schemaDict:
+		list := make([]schemaDict, len(v))
+		for i, d := range v {
+			list[i] = cloneDecl(d).(schemaDict)
+		}
+		return list
+	}
+
+	if value := reflect.ValueOf(v); value.Kind() == reflect.Slice {
+		clone := reflect.MakeSlice(value.Type(), value.Len(), value.Len())
+		reflect.Copy(clone, value)
+		return clone.Interface()
+	}
+	return v
+}
+
+// withPresetRef handles the `preset_ref` Kong tag, merging the attributes
+// of a preset from the WithPresetFile file into a field's declaration, as
+// in `kong:"preset_ref=standard_timeout"`.  They replace those derived
+// from the Go type, and are replaced by the other entries of the tag.  A
+// preset giving another type than the field's is not applied.
+func (b *schemaBuilder) withPresetRef(decl schemaDict, name string, path string) {
+	fragment, ok := b.presetRefs[name]
+	if !ok {
+		b.warnf("field %s: unknown preset_ref %q", path, name)
+		return
+	}
+	if typ, ok := fragment["type"]; ok && typ != decl["type"] {
+		b.warnf("field %s: preset_ref %q is for type %v, not %v", path, name, typ, decl["type"])
+		return
+	}
+
+	keys := make([]string, 0, len(fragment))
+	for key := range fragment {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		decl[key] = cloneDecl(fragment[key])
+	}
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPresetFile(t *testing.T) {
+	type Config struct {
+		Timeout   int      `json:"timeout" kong:"preset_ref=standard_timeout"`
+		Short     int      `json:"short" kong:"preset_ref=standard_timeout,default=1000"`
+		Headers   []string `json:"headers" kong:"preset_ref=common_headers"`
+		Mismatch  string   `json:"mismatch" kong:"preset_ref=standard_timeout"`
+		Undefined int      `json:"undefined" kong:"preset_ref=nope"`
+	}
+	constructor := func() interface{} { return &Config{} }
+
+	path := filepath.Join(t.TempDir(), "presets.json")
+	assert.NoError(t, os.WriteFile(path, []byte(`{
+		"standard_timeout": {"type": "integer", "default": 30000, "between": [1, 60000]},
+		"common_headers": {"elements": {"type": "string", "one_of": ["x-request-id", "x-trace"]}, "len_max": 2}
+	}`), 0o600))
+	rh := newRpcHandler(constructor, "0.1", 1, WithPresetFile(path))
+
+	b := rh.newSchemaBuilder()
+	schema := b.getSchemaDict(reflect.TypeOf(Config{}), "config")
+	assert.Equal(t, []schemaDict{
+		{"timeout": schemaDict{"type": "integer", "default": 30000, "between": []int{1, 60000}}},
+		{"short": schemaDict{"type": "integer", "default": "1000", "between": []int{1, 60000}}},
+		{"headers": schemaDict{
+			"type":     "array",
+			"elements": schemaDict{"type": "string", "one_of": []string{"x-request-id", "x-trace"}},
+			"len_max":  2,
+		}},
+		{"mismatch": schemaDict{"type": "string"}},
+		{"undefined": schemaDict{"type": "integer"}},
+	}, schema["fields"])
+	assert.Equal(t, []string{
+		`field config.mismatch: preset_ref "standard_timeout" is for type integer, not string`,
+		`field config.undefined: unknown preset_ref "nope"`,
+	}, b.warnings)
+
+	assert.Nil(t, newRpcHandler(constructor, "0.1", 1, WithPresetFile(filepath.Join(t.TempDir(), "missing.json"))))
+}
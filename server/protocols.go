@@ -0,0 +1,58 @@
+package server
+
+import "slices"
+
+// Protocols of the routes and services going through each Kong subsystem.
+var subsystemProtocols = map[string][]string{
+	"http":   {"http", "https", "grpc", "grpcs"},
+	"stream": {"tcp", "tls", "udp"},
+}
+
+// getProtocols returns the protocols a plugin applies to by default: those
+// of the subsystems where it handles events only that subsystem runs, or
+// of both if there are none.  WithProtocols overrides them.
+func (rh *rpcHandler) getProtocols() []string {
+	if rh.protocols != nil {
+		return rh.protocols
+	}
+
+	phases := getHandlerNames(rh.configType)
+	protocols := []string{}
+	for _, subsystem := range []string{"http", "stream"} {
+		for _, phase := range phases {
+			if onlyIn(phase, subsystem) {
+				protocols = append(protocols, subsystemProtocols[subsystem]...)
+				break
+			}
+		}
+	}
+
+	if len(protocols) == 0 {
+		protocols = allProtocols()
+	}
+	return protocols
+}
+
+// onlyIn tells whether a phase runs on the given subsystem and no other.
+func onlyIn(phase, subsystem string) bool {
+	for other, phases := range subsystemPhases {
+		if slices.Contains(phases, phase) != (other == subsystem) {
+			return false
+		}
+	}
+	return true
+}
+
+// protocolsField declares the plugin's protocols field, with its default.
+func (rh *rpcHandler) protocolsField() schemaDict {
+	return schemaDict{"protocols": schemaDict{
+		"type":     "set",
+		"required": true,
+		"default":  rh.getProtocols(),
+		"elements": schemaDict{"type": "string", "one_of": allProtocols()},
+	}}
+}
+
+func allProtocols() []string {
+	return append(slices.Clone(subsystemProtocols["http"]), subsystemProtocols["stream"]...)
+}
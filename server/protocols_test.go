@@ -0,0 +1,39 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/Kong/go-pdk"
+	"github.com/stretchr/testify/assert"
+)
+
+type httpConfig struct{}
+
+func (c httpConfig) Access(kong *pdk.PDK) {}
+func (c httpConfig) Log(kong *pdk.PDK)    {}
+
+type streamConfig struct{}
+
+func (c streamConfig) Preread(kong *pdk.PDK) {}
+func (c streamConfig) Log(kong *pdk.PDK)     {}
+
+func defaultProtocols(t *testing.T, constructor func() interface{}, opts ...ServerOption) interface{} {
+	rh := newRpcHandler(constructor, "0.1", 1, opts...)
+	schema, err := rh.getSchema("test")
+	assert.NoError(t, err)
+
+	field := schema["fields"].([]schemaDict)[0]["protocols"].(schemaDict)
+	assert.Equal(t, "set", field["type"])
+	return field["default"]
+}
+
+func TestDefaultProtocols(t *testing.T) {
+	assert.Equal(t, []string{"http", "https", "grpc", "grpcs"},
+		defaultProtocols(t, func() interface{} { return &httpConfig{} }))
+	assert.Equal(t, []string{"tcp", "tls", "udp"},
+		defaultProtocols(t, func() interface{} { return &streamConfig{} }))
+	assert.Equal(t, []string{"http", "https", "grpc", "grpcs", "tcp", "tls", "udp"},
+		defaultProtocols(t, func() interface{} { return &multiProtocolConfig{} }))
+	assert.Equal(t, []string{"https"},
+		defaultProtocols(t, func() interface{} { return &httpConfig{} }, WithProtocols("https")))
+}
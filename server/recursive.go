@@ -0,0 +1,62 @@
+package server
+
+import (
+	"reflect"
+	"sync"
+)
+
+// Levels a type registered with RegisterRecursive is declared to, counting
+// the outermost one.
+const recursionDepth = 4
+
+var recursiveTypes = struct {
+	lock  sync.RWMutex
+	types map[reflect.Type]bool
+}{
+	types: map[reflect.Type]bool{},
+}
+
+// RegisterRecursive allows the type of v, a struct referring to itself (as
+// a rule with sub-rules), in config types.  Kong schemas can't refer to a
+// declaration by name, so the type is declared inline, recursionDepth (4)
+// levels deep: the fields referring to it are left out of the innermost
+// level, so Kong rejects configurations nesting it deeper.  Without it, a
+// recursive config type is refused at startup.
+func RegisterRecursive(v interface{}) {
+	t := derefType(reflect.TypeOf(v))
+
+	recursiveTypes.lock.Lock()
+	defer recursiveTypes.lock.Unlock()
+	recursiveTypes.types[t] = true
+}
+
+func isRecursiveAllowed(t reflect.Type) bool {
+	recursiveTypes.lock.RLock()
+	defer recursiveTypes.lock.RUnlock()
+	return recursiveTypes.types[t]
+}
+
+// recursionDecl returns the declaration of the struct type t, keeping
+// count of the types being walked.  A type found within itself is declared
+// again up to recursionDepth levels if registered with RegisterRecursive,
+// and is otherwise an error, found at the given path.  Returns nil where
+// the type isn't declared.
+func (b *schemaBuilder) recursionDecl(t reflect.Type, path string) schemaDict {
+	if b.walking == nil {
+		b.walking = map[reflect.Type]int{}
+	}
+
+	if depth := b.walking[t]; depth > 0 {
+		if !isRecursiveAllowed(t) {
+			b.errorf("field %s: type %s refers to itself; register it with RegisterRecursive to declare it %d levels deep", path, t, recursionDepth)
+			return nil
+		}
+		if depth >= recursionDepth {
+			return nil
+		}
+	}
+
+	b.walking[t]++
+	defer func() { b.walking[t]-- }()
+	return b.typeDecl(t, path)
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type ruleConfig struct {
+	Match string        `json:"match"`
+	Rules []*ruleConfig `json:"rules"`
+}
+
+type loopConfig struct {
+	Name string      `json:"name"`
+	Next *loopConfig `json:"next"`
+}
+
+func TestRecursiveType(t *testing.T) {
+	RegisterRecursive(&ruleConfig{})
+	t.Cleanup(func() { delete(recursiveTypes.types, reflect.TypeOf(ruleConfig{})) })
+
+	type Config struct {
+		Root ruleConfig `json:"root"`
+	}
+
+	// the innermost level has no sub-rules
+	rule := schemaDict{"type": "record", "fields": []schemaDict{
+		{"match": schemaDict{"type": "string"}},
+	}}
+	for i := 1; i < recursionDepth; i++ {
+		rule = schemaDict{"type": "record", "fields": []schemaDict{
+			{"match": schemaDict{"type": "string"}},
+			{"rules": schemaDict{"type": "array", "elements": rule}},
+		}}
+	}
+
+	description, err := Describe(func() interface{} { return &Config{} }, "0.1", 1, WithName("test"))
+	assert.NoError(t, err)
+	assert.Equal(t, schemaDict{"type": "record", "fields": []schemaDict{{"root": rule}}},
+		description.Schema["fields"].([]schemaDict)[1]["config"])
+
+	problems, err := AssertKongCompatible(description.Schema, "3.9")
+	assert.NoError(t, err)
+	assert.Empty(t, problems)
+}
+
+func TestUnregisteredRecursiveType(t *testing.T) {
+	type Config struct {
+		List loopConfig `json:"list"`
+	}
+
+	b := &schemaBuilder{}
+	b.getSchemaDict(reflect.TypeOf(Config{}), "config")
+	assert.Len(t, b.errs, 1)
+	assert.EqualError(t, b.errs[0], "field config.list.next: type server.loopConfig refers to itself; register it with RegisterRecursive to declare it 4 levels deep")
+
+	assert.Nil(t, newRpcHandler(func() interface{} { return &Config{} }, "0.1", 1))
+}
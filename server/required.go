@@ -0,0 +1,31 @@
+package server
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// Required wraps the type of a config field to declare it required, as
+// tagging it with `kong:"required=true"` would:
+//
+//	type Config struct {
+//		Host server.Required[string] `json:"host"`
+//	}
+//
+// It's decoded from, and encoded to, the JSON form of its Value.  A
+// *Required[T] field is required as well, unlike other pointer fields.
+type Required[T any] struct {
+	Value T
+}
+
+func (r *Required[T]) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &r.Value)
+}
+
+func (r Required[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.Value)
+}
+
+func (Required[T]) kongRequired() {}
+
+var requiredMarkerType = reflect.TypeOf((*interface{ kongRequired() })(nil)).Elem()
@@ -1,24 +1,59 @@
 package server
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"reflect"
+	"slices"
 	"strings"
 	"sync"
 	"time"
-
-	"slices"
 )
 
 type rpcHandler struct {
-	constructor       func() interface{}
-	configType        reflect.Type
-	version           string // version number
-	priority          int    // priority info
-	lock              sync.RWMutex
-	instances         map[int]*instanceData
-	events            map[int]*eventData
-	lastCloseInstance time.Time
+	constructor        func() interface{}
+	configType         reflect.Type
+	version            string // version number
+	priority           int    // priority info
+	lock               sync.RWMutex
+	instances          map[int]*instanceData
+	events             map[int]*eventData
+	lastCloseInstance  time.Time
+	latestInstances    map[string]*instanceData // latest instance, by plugin key
+	preloadConfig      []byte                   // configuration to start an instance with on startup
+	preloaded          *instanceData            // instance started from preloadConfig, until adopted
+	lastPhases         map[string]bool          // handled events ending a request
+	schemaOptions      schemaOptions
+	noSchema           bool   // don't describe the config type to Kong
+	name               string // plugin name, if not derived from the executable
+	pprofAddr          string // address to serve pprof on, if enabled
+	middleware         []Middleware
+	onShutdown         func() error
+	shutdownTimeout    time.Duration
+	protocolPhases     bool // report the handled events of each subsystem
+	maxConfigSize      int  // largest config data accepted, in bytes
+	strictDecoding     bool // reject config data with unknown keys
+	phaseTimeout       time.Duration
+	metrics            Metrics
+	listenNetwork      string // network to listen on, if not the Kong socket
+	listenAddress      string
+	allowedListenHosts []string // non-loopback hosts allowed to listen on
+	breakerThreshold   int      // consecutive timeouts short-circuiting a handler
+	breakerCooldown    time.Duration
+	breakerPolicy      DegradePolicy
+	asyncConfigure     bool // run Configure hooks off the StartInstance call
+	pendingPolicy      PendingPolicy
+	onConfigChange     func(ConfigChange)
+	poolSize           int         // workers handling events, if limited
+	pool               *workerPool // started by StartServer
+	startupChecks      bool        // look for handlers not sharing the config's state
+	memoryCap          int         // total size of instances, in bytes, if limited
+	protocols          []string    // default protocols, if not derived from phases
+	defaultsFile       string      // JSON file with defaults for unset fields
+	configDefaults     map[string]interface{}
+	presetFile         string // JSON file with field attributes for preset_ref tags
+	nullablePointers   bool   // mark optional pointers as nullable in Describe's schema
 }
 
 var methodNames = [...]string{
@@ -41,150 +76,181 @@ func getHandlerNames(t reflect.Type) []string {
 	return handlers
 }
 
-func newRpcHandler(constructor func() interface{}, version string, priority int) *rpcHandler {
+// checkPhaseMethods makes sure every phase method of the config type t is
+// one the server calls: with the signature of a handler, and in the method
+// set of t itself, not only of *t.
+func checkPhaseMethods(t reflect.Type) error {
+	handlers := getHandlers(reflect.Zero(t).Interface())
+	for _, name := range methodNames {
+		method, ok := t.MethodByName(name)
+		if !ok {
+			if t.Kind() != reflect.Ptr {
+				if _, ok := reflect.PointerTo(t).MethodByName(name); ok {
+					return fmt.Errorf("%s method is defined on *%s, but the constructor returns a %s", name, t, t)
+				}
+			}
+			continue
+		}
+		if _, ok := handlers[strings.ToLower(name)]; !ok {
+			return fmt.Errorf("%s method of %s isn't a phase handler: has type %s", name, t, method.Type)
+		}
+	}
 
+	return nil
+}
+
+func newRpcHandler(constructor func() interface{}, version string, priority int, opts ...ServerOption) *rpcHandler {
+	rh, err := buildRpcHandler(constructor, version, priority, opts...)
+	if err != nil {
+		log.Print(err)
+		return nil
+	}
+
+	return rh
+}
+
+// buildRpcHandler is newRpcHandler, returning why the plugin can't be
+// served instead of logging it.
+func buildRpcHandler(constructor func() interface{}, version string, priority int, opts ...ServerOption) (*rpcHandler, error) {
 	constructorType := reflect.TypeOf(constructor)
 	if constructorType == nil {
-		log.Printf("nil constructor")
-		return nil
+		return nil, errors.New("nil constructor")
 	}
 
 	if constructorType.Kind() != reflect.Func {
-		log.Printf("Constructor is not a function")
-		return nil
+		return nil, errors.New("constructor is not a function")
 	}
 
 	if constructorType.NumIn() != 0 || constructorType.NumOut() != 1 {
-		log.Printf("Wrong constructor signature")
-		return nil
+		return nil, errors.New("wrong constructor signature")
 	}
 
-	return &rpcHandler{
-		constructor: constructor,
-		configType:  reflect.TypeOf(constructor()),
-		version:     version,
-		priority:    priority,
-		instances:   map[int]*instanceData{},
-		events:      map[int]*eventData{},
+	configType := reflect.TypeOf(constructor())
+	if configType != nil {
+		if err := checkPhaseMethods(configType); err != nil {
+			return nil, fmt.Errorf("invalid config type: %w", err)
+		}
 	}
-}
 
-type schemaDict map[string]interface{}
+	rh := &rpcHandler{
+		constructor:     constructor,
+		configType:      configType,
+		version:         version,
+		priority:        priority,
+		instances:       map[int]*instanceData{},
+		events:          map[int]*eventData{},
+		latestInstances: map[string]*instanceData{},
+	}
 
-func getSchemaDict(t reflect.Type) schemaDict {
-	switch t.Kind() {
-	case reflect.String:
-		return schemaDict{"type": "string"}
+	if configType != nil {
+		rh.lastPhases = getLastPhases(getHandlerNames(configType))
+	}
 
-	case reflect.Bool:
-		return schemaDict{"type": "boolean"}
+	for _, opt := range opts {
+		opt(rh)
+	}
 
-	case reflect.Int, reflect.Int32:
-		return schemaDict{"type": "integer"}
+	if err := rh.loadDefaults(); err != nil {
+		return nil, fmt.Errorf("loading config defaults: %w", err)
+	}
 
-	case reflect.Uint, reflect.Uint32:
-		return schemaDict{
-			"type":    "integer",
-			"between": []int{0, 2147483648},
-		}
+	if err := rh.loadPresetFile(); err != nil {
+		return nil, fmt.Errorf("loading presets: %w", err)
+	}
 
-	case reflect.Float32, reflect.Float64:
-		return schemaDict{"type": "number"}
+	if rh.startupChecks && rh.configType != nil {
+		for _, warning := range handlerWarnings(rh.configType) {
+			log.Printf("config type: %s", warning)
+		}
+	}
 
-	case reflect.Ptr:
-		return getSchemaDict(t.Elem())
+	// schema errors name the field at fault, as in "field config.x: ..."
+	if err := rh.checkSchema(); err != nil {
+		return nil, err
+	}
 
-	case reflect.Slice:
-		elemType := getSchemaDict(t.Elem())
-		if elemType == nil {
-			break
-		}
-		return schemaDict{
-			"type":     "array",
-			"elements": elemType,
-		}
+	return rh, nil
+}
 
-	case reflect.Map:
-		kType := getSchemaDict(t.Key())
-		vType := getSchemaDict(t.Elem())
-		if kType == nil || vType == nil {
-			break
-		}
-		return schemaDict{
-			"type":   "map",
-			"keys":   kType,
-			"values": vType,
-		}
+// checkSchema logs any problem found generating the config schema,
+// so that mistakes in the config type show up at startup.  Returns those
+// that prevent serving the plugin.
+func (rh *rpcHandler) checkSchema() error {
+	if rh.configType == nil || rh.noSchema {
+		return nil
+	}
 
-	case reflect.Struct:
-		fieldsArray := []schemaDict{}
-		for i := 0; i < t.NumField(); i++ {
-			field := t.Field(i)
-			// ignore unexported fields
-			if len(field.PkgPath) != 0 {
-				continue
-			}
-			typeDecl := getSchemaDict(field.Type)
-			if typeDecl == nil {
-				// ignore unrepresentable types
-				continue
-			}
-			name := field.Tag.Get("json")
-			if name == "" {
-				name = strings.ToLower(field.Name)
-			}
-			// Apply Kong tags to the field's type declaration
-			typeDeclWithKong := withKongTagFields(typeDecl, field)
-			fieldsArray = append(fieldsArray, schemaDict{name: typeDeclWithKong})
-		}
-		return schemaDict{
-			"type":   "record",
-			"fields": fieldsArray,
-		}
+	b := rh.newSchemaBuilder()
+	b.getSchemaDict(rh.configType, "config")
+	for _, warning := range b.warnings {
+		log.Printf("config schema: %s", warning)
 	}
 
-	return nil
+	return errors.Join(b.errs...)
 }
 
-func withKongTagFields(current schemaDict, field reflect.StructField) schemaDict {
-	var validFields = []string{"required", "default"}
-	var boolFields = []string{"required"}
-	result := current
-	tag := field.Tag.Get("kong")
-	if tag == "" {
-		return result
-	}
+type pluginInfo struct {
+	Name           string              // plugin name
+	ModTime        time.Time           `codec:",omitempty"` // plugin file modification time
+	LoadTime       time.Time           `codec:",omitempty"` // plugin load time
+	Phases         []string            // events it can handle
+	ProtocolPhases map[string][]string `codec:",omitempty"` // events it can handle, by subsystem
+	Version        string              // version number
+	Priority       int                 // priority info
+	Schema         schemaDict          // representation of the config schema
+	PdkVersion     string              `codec:",omitempty"` // go-pdk version the plugin was built with
+	GoVersion      string              `codec:",omitempty"` // Go toolchain the plugin was built with
+}
 
-	tagMap := strings.Split(tag, ",")
-	for _, tag := range tagMap {
-		parts := strings.Split(tag, "=")
-		if len(parts) != 2 {
-			continue
-		}
-		if slices.Contains(validFields, parts[0]) {
-			result[parts[0]] = parts[1]
+// Events Kong runs on each of its subsystems.
+var subsystemPhases = map[string][]string{
+	"http":   {"certificate", "rewrite", "access", "response", "log"},
+	"stream": {"certificate", "preread", "log"},
+}
+
+// getLastPhases returns, of the handled events, the last one each
+// subsystem runs for a request, after which its request store can go.  The
+// certificate phase runs once for a connection, not for each request.
+func getLastPhases(phases []string) map[string]bool {
+	last := map[string]bool{}
+	for _, subsystemPhases := range subsystemPhases {
+		for i := len(subsystemPhases) - 1; i > 0; i-- {
+			if slices.Contains(phases, subsystemPhases[i]) {
+				last[subsystemPhases[i]] = true
+				break
+			}
 		}
+	}
+	return last
+}
 
-		if slices.Contains(boolFields, parts[0]) {
-			result[parts[0]] = parts[1] == "true"
+// getProtocolPhases splits the handled events by the subsystems that run
+// them.  Subsystems without any handled event are left out.
+func getProtocolPhases(phases []string) map[string][]string {
+	protocolPhases := map[string][]string{}
+	for subsystem, subsystemPhases := range subsystemPhases {
+		for _, phase := range phases {
+			if slices.Contains(subsystemPhases, phase) {
+				protocolPhases[subsystem] = append(protocolPhases[subsystem], phase)
+			}
 		}
 	}
 
-	return result
+	return protocolPhases
 }
 
-type pluginInfo struct {
-	Name     string     // plugin name
-	ModTime  time.Time  `codec:",omitempty"` // plugin file modification time
-	LoadTime time.Time  `codec:",omitempty"` // plugin load time
-	Phases   []string   // events it can handle
-	Version  string     // version number
-	Priority int        // priority info
-	Schema   schemaDict // representation of the config schema
+// getName returns the plugin name given by WithName, or the one derived
+// from the executable name otherwise.
+func (rh *rpcHandler) getName() (string, error) {
+	if rh.name != "" {
+		return rh.name, nil
+	}
+
+	return getName()
 }
 
 func (rh *rpcHandler) getInfo() (info pluginInfo, err error) {
-	name, err := getName()
+	name, err := rh.getName()
 	if err != nil {
 		return
 	}
@@ -195,21 +261,44 @@ func (rh *rpcHandler) getInfo() (info pluginInfo, err error) {
 	}
 
 	info = pluginInfo{
-		Name:     name,
-		Phases:   getHandlerNames(rh.configType),
-		Schema:   schema,
-		Version:  rh.version,
-		Priority: rh.priority,
+		Name:       name,
+		Phases:     getHandlerNames(rh.configType),
+		Schema:     schema,
+		Version:    rh.version,
+		Priority:   rh.priority,
+		PdkVersion: pdkVersion(),
+		GoVersion:  goVersion(),
+	}
+
+	if rh.protocolPhases {
+		info.ProtocolPhases = getProtocolPhases(info.Phases)
 	}
 
 	return
 }
 
 func (rh *rpcHandler) getSchema(name string) (schema schemaDict, err error) {
-	return schemaDict{
+	if rh.noSchema {
+		return schemaDict{
+			"name":   name,
+			"fields": []schemaDict{},
+		}, nil
+	}
+
+	b := rh.newSchemaBuilder()
+	config := b.getSchemaDict(rh.configType, "config")
+
+	schema = schemaDict{
 		"name": name,
 		"fields": []schemaDict{
-			{"config": getSchemaDict(rh.configType)},
+			rh.protocolsField(),
+			{"config": config},
 		},
-	}, nil
+	}
+
+	if len(b.entityChecks) > 0 {
+		schema["entity_checks"] = b.entityChecks
+	}
+
+	return
 }
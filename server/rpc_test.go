@@ -4,6 +4,7 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/Kong/go-pdk"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -15,7 +16,7 @@ func TestGetSchemaDict(t *testing.T) {
 		NoJsonTag           string `kong:"default=no_json_tag"`
 	}
 
-	schema := GetSchemaDict(reflect.TypeOf(Config{}))
+	schema := getSchemaDict(reflect.TypeOf(Config{}))
 	assert.Equal(t, schema, schemaDict{
 		"type": "record",
 		"fields": []schemaDict{
@@ -26,3 +27,127 @@ func TestGetSchemaDict(t *testing.T) {
 		},
 	})
 }
+
+func TestScopeRequiredFields(t *testing.T) {
+	type Config struct {
+		Token  string `json:"token" kong:"required_on=route;service"`
+		Prefix string `json:"prefix"`
+	}
+
+	rh := newRpcHandler(func() interface{} { return &Config{} }, "0.1", 1)
+	schema, err := rh.getSchema("test")
+	assert.NoError(t, err)
+	assert.Equal(t, []schemaDict{
+		{"conditional": schemaDict{
+			"if_field":   "route",
+			"if_match":   schemaDict{"required": true},
+			"then_field": "config.token",
+			"then_match": schemaDict{"required": true},
+		}},
+		{"conditional": schemaDict{
+			"if_field":   "service",
+			"if_match":   schemaDict{"required": true},
+			"then_field": "config.token",
+			"then_match": schemaDict{"required": true},
+		}},
+	}, schema["entity_checks"])
+}
+
+func TestUnknownKongTagKeys(t *testing.T) {
+	type Config struct {
+		Token string `json:"token" kong:"requried=true"`
+	}
+
+	var b schemaBuilder
+	schema := b.getSchemaDict(reflect.TypeOf(Config{}), "config")
+	assert.Equal(t, schemaDict{
+		"type": "record",
+		"fields": []schemaDict{
+			{"token": schemaDict{"type": "string"}},
+		},
+	}, schema)
+	assert.Equal(t, []string{`field config.token: ignoring unknown kong tag key "requried"`}, b.warnings)
+}
+
+func TestWithoutSchema(t *testing.T) {
+	type Config struct {
+		Internal string `json:"internal" kong:"required=true"`
+	}
+
+	rh := newRpcHandler(func() interface{} { return &Config{} }, "0.1", 1, WithoutSchema())
+	schema, err := rh.getSchema("test")
+	assert.NoError(t, err)
+	assert.Equal(t, schemaDict{
+		"name":   "test",
+		"fields": []schemaDict{},
+	}, schema)
+}
+
+func TestWithName(t *testing.T) {
+	type Config struct {
+		Message string `json:"message"`
+	}
+
+	rh := newRpcHandler(func() interface{} { return &Config{} }, "0.1", 1, WithName("my-plugin"))
+	info, err := rh.getInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, "my-plugin", info.Name)
+	assert.Equal(t, "my-plugin", info.Schema["name"])
+}
+
+func TestFieldGroups(t *testing.T) {
+	type Config struct {
+		ApiKey   string `json:"api_key" kong:"group=auth,group_policy=only_one"`
+		Token    string `json:"token" kong:"group=auth"`
+		Insecure bool   `json:"insecure"`
+	}
+
+	rh := newRpcHandler(func() interface{} { return &Config{} }, "0.1", 1)
+	schema, err := rh.getSchema("test")
+	assert.NoError(t, err)
+	assert.Equal(t, []schemaDict{
+		{"only_one_of": []string{"config.api_key", "config.token"}},
+	}, schema["entity_checks"])
+}
+
+type multiProtocolConfig struct{}
+
+func (c multiProtocolConfig) Access(kong *pdk.PDK)  {}
+func (c multiProtocolConfig) Preread(kong *pdk.PDK) {}
+func (c multiProtocolConfig) Log(kong *pdk.PDK)     {}
+
+func TestProtocolPhases(t *testing.T) {
+	constructor := func() interface{} { return &multiProtocolConfig{} }
+
+	info, err := newRpcHandler(constructor, "0.1", 1, WithName("test")).getInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"access", "preread", "log"}, info.Phases)
+	assert.Nil(t, info.ProtocolPhases)
+
+	info, err = newRpcHandler(constructor, "0.1", 1, WithName("test"), WithProtocolPhases()).getInfo()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"http":   {"access", "log"},
+		"stream": {"preread", "log"},
+	}, info.ProtocolPhases)
+}
+
+type pointerPhaseConfig struct{}
+
+func (c *pointerPhaseConfig) Access(kong *pdk.PDK) {}
+
+type wrongPhaseConfig struct{}
+
+func (c wrongPhaseConfig) Access() {}
+
+func TestCheckPhaseMethods(t *testing.T) {
+	assert.NoError(t, checkPhaseMethods(reflect.TypeOf(&pointerPhaseConfig{})))
+	assert.NoError(t, checkPhaseMethods(reflect.TypeOf(multiProtocolConfig{})))
+
+	assert.EqualError(t, checkPhaseMethods(reflect.TypeOf(pointerPhaseConfig{})),
+		"Access method is defined on *server.pointerPhaseConfig, but the constructor returns a server.pointerPhaseConfig")
+	assert.EqualError(t, checkPhaseMethods(reflect.TypeOf(wrongPhaseConfig{})),
+		"Access method of server.wrongPhaseConfig isn't a phase handler: has type func(server.wrongPhaseConfig)")
+
+	assert.Nil(t, newRpcHandler(func() interface{} { return pointerPhaseConfig{} }, "0.1", 1))
+}
@@ -0,0 +1,817 @@
+package server
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type schemaDict map[string]interface{}
+
+// A Schemer gives its own Kong schema declaration, instead of the one that
+// would be derived from its Go type.
+type Schemer interface {
+	KongSchema() map[string]interface{}
+}
+
+// An Enum is a string type whose values are restricted to those returned by
+// Values, called on its zero value.  Fields of such types are declared with
+// a one_of of them.
+type Enum interface {
+	Values() []string
+}
+
+var enumType = reflect.TypeOf((*Enum)(nil)).Elem()
+
+// schemaOptions are the server options that change the generated schema.
+type schemaOptions struct {
+	deriveLenMax   bool                  // emit len_max for strings with one_of, if not given
+	recordDefaults bool                  // compose record defaults from their fields' defaults
+	kongVersion    kongVersion           // leave out keys unknown to this Kong version
+	strict         bool                  // reject fields with both between and one_of
+	oneOfDefaults  bool                  // default fields with one_of to its first value, if not given
+	nullable       bool                  // mark optional pointer fields as nullable, for Describe
+	presetRefs     map[string]schemaDict // field attributes from the preset file, by name
+}
+
+// schemaBuilder walks a config type producing its schema.  Besides the field
+// declarations, it collects the checks that Kong expects at the schema level.
+type schemaBuilder struct {
+	schemaOptions
+	entityChecks []schemaDict         // entity_checks, as referenced by field path
+	warnings     []string             // problems found in the config type
+	errs         []error              // problems that prevent serving the plugin
+	walking      map[reflect.Type]int // struct types being walked, with their depth
+}
+
+func (b *schemaBuilder) warnf(format string, args ...interface{}) {
+	b.warnings = append(b.warnings, fmt.Sprintf(format, args...))
+}
+
+func (b *schemaBuilder) errorf(format string, args ...interface{}) {
+	b.errs = append(b.errs, fmt.Errorf(format, args...))
+}
+
+func (rh *rpcHandler) newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{schemaOptions: rh.schemaOptions}
+}
+
+func getSchemaDict(t reflect.Type) schemaDict {
+	var b schemaBuilder
+	return b.getSchemaDict(t, "config")
+}
+
+// getSchemaDict returns the declaration for type t, found at the given
+// field path (dot-separated, as Kong's entity checks expect).
+// Named types are described by their underlying kind, so a type like
+// url.Values is a map of strings to arrays of strings.  Empty interfaces
+// (as in map[string]interface{}) take any JSON value; fields of other
+// interface types, like error, are left out with a warning.
+//
+// Kong checks len_min and len_max in bytes.  For text that may hold multibyte
+// characters, `kong:"len_unit=rune"` takes the tagged lengths as a number of
+// runes; as Kong has no such validator, len_max is widened to the most bytes
+// that many runes can take, so it won't reject valid values (but may let some
+// longer ones through).
+//
+// Pointer fields (*T) are taken to be optional, and declared with
+// "required": false (Kong would otherwise require nested records).
+// Tagging them with `kong:"required=true"` overrides it.
+//
+// time.Duration fields are integers, counted in nanoseconds unless given a
+// unit, as in `kong:"unit=s,default=30"`.  Their default is then emitted in
+// that unit, and the decoded config holds the actual duration.
+func (b *schemaBuilder) getSchemaDict(t reflect.Type, path string) schemaDict {
+	if t.Kind() == reflect.Struct {
+		return b.recursionDecl(t, path)
+	}
+
+	return b.typeDecl(t, path)
+}
+
+// typeDecl returns the declaration of type t, found at the given path.
+func (b *schemaBuilder) typeDecl(t reflect.Type, path string) schemaDict {
+	if s, ok := reflect.New(t).Interface().(Schemer); ok {
+		return schemaDict(maps.Clone(s.KongSchema()))
+	}
+
+	// a Required[T] is declared as a required T (*Required[T] has the
+	// marker method too, but is handled as any pointer)
+	if t.Kind() == reflect.Struct && t.Implements(requiredMarkerType) {
+		decl := b.getSchemaDict(t.Field(0).Type, path)
+		if decl != nil {
+			decl["required"] = true
+		}
+		return decl
+	}
+
+	if t.Kind() == reflect.String && reflect.PointerTo(t).Implements(enumType) {
+		values := reflect.New(t).Interface().(Enum).Values()
+		return schemaDict{"type": "string", "one_of": slices.Clone(values)}
+	}
+
+	// encoding/json reads time.Time values as RFC 3339 strings
+	if t == timeType {
+		return schemaDict{"type": "string"}
+	}
+
+	// encoding/json reads time.Duration values as integers; a `unit` tag
+	// gives the one they're counted in
+	if t == durationType {
+		return schemaDict{"type": "integer"}
+	}
+
+	// the JSON form of a json.Marshaler can be anything
+	if reflect.PointerTo(t).Implements(jsonMarshalerType) {
+		return schemaDict{"type": "json"}
+	}
+
+	// encoding/json reads those from strings, whatever their kind, and so
+	// are the elements of slices of them.  That includes regexp.Regexp,
+	// compiled when decoding, so an invalid pattern fails the instance.
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return schemaDict{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return schemaDict{"type": "string"}
+
+	case reflect.Bool:
+		return schemaDict{"type": "boolean"}
+
+	case reflect.Int, reflect.Int32:
+		return schemaDict{"type": "integer"}
+
+	case reflect.Uint, reflect.Uint32:
+		return schemaDict{
+			"type":    "integer",
+			"between": []int{0, 2147483648},
+		}
+
+	case reflect.Float32, reflect.Float64:
+		return schemaDict{"type": "number"}
+
+	case reflect.Ptr:
+		return b.getSchemaDict(t.Elem(), path)
+
+	// encoding/json decodes any value into an empty interface, but into
+	// no other: a field of type error is most likely a mistake
+	case reflect.Interface:
+		if t.NumMethod() == 0 {
+			return schemaDict{"type": "json"}
+		}
+		b.warnf("field %s: interface type %s can't be decoded from the config, leaving it out", path, t)
+
+	case reflect.Slice:
+		elemType := b.getSchemaDict(t.Elem(), path)
+		if elemType == nil {
+			break
+		}
+		return schemaDict{
+			"type":     "array",
+			"elements": elemType,
+		}
+
+	case reflect.Map:
+		kType := b.getSchemaDict(t.Key(), path)
+		vType := b.getSchemaDict(t.Elem(), path)
+		if kType == nil || vType == nil {
+			break
+		}
+		return schemaDict{
+			"type":   "map",
+			"keys":   kType,
+			"values": vType,
+		}
+
+	case reflect.Struct:
+		fieldsArray := []schemaDict{}
+		groups := fieldGroups{}
+		for i := 0; i < t.NumField(); i++ {
+			fieldsArray = append(fieldsArray, b.fieldDecls(t.Field(i), path, &groups)...)
+		}
+		b.addGroupChecks(groups)
+		record := schemaDict{
+			"type":   "record",
+			"fields": fieldsArray,
+		}
+		if b.recordDefaults {
+			if def, ok := recordDefault(fieldsArray); ok {
+				record["default"] = def
+			}
+		}
+		return record
+	}
+
+	return nil
+}
+
+// fieldDecls returns the declarations of a struct field, found in the
+// record at the given path: none if it's left out, and those of its fields
+// if it's an embedded struct json flattens.  A panic walking the field's
+// type (as from a KongSchema method) is reported as an error naming the
+// field, instead of crashing the server with no hint of where it comes from.
+func (b *schemaBuilder) fieldDecls(field reflect.StructField, path string, groups *fieldGroups) (decls []schemaDict) {
+	fieldPath := path + "." + field.Name
+	defer func() {
+		if r := recover(); r != nil {
+			b.errorf("field %s: panic generating its schema: %v", fieldPath, r)
+			decls = nil
+		}
+	}()
+
+	if embedded, ok := flattened(field); ok {
+		// encoding/json reads the fields of embedded structs as
+		// if they were the outer struct's, allocating pointers
+		if decl := b.getSchemaDict(embedded, path); decl["type"] == "record" {
+			fields, _ := decl["fields"].([]schemaDict)
+			return fields
+		}
+	}
+	// ignore unexported fields
+	if len(field.PkgPath) != 0 {
+		return nil
+	}
+	name, ok := fieldName(field)
+	if !ok {
+		return nil
+	}
+	fieldPath = path + "." + name
+	if path == "config" && slices.Contains(reservedKeys, name) {
+		b.warnf("field %s: %q is reserved by Kong", fieldPath, name)
+	}
+	typeDecl := b.getSchemaDict(field.Type, fieldPath)
+	if preset, ok := kongTagValue(field, "preset"); ok {
+		if decl, ok := presetDecl(preset); ok {
+			typeDecl = decl
+		} else {
+			b.warnf("field %s: unknown preset %q", fieldPath, preset)
+		}
+	}
+	if typeDecl == nil {
+		// ignore unrepresentable types
+		return nil
+	}
+	if field.Type.Kind() == reflect.Ptr && !derefType(field.Type).Implements(requiredMarkerType) {
+		typeDecl["required"] = false
+	}
+	if ref, ok := kongTagValue(field, "preset_ref"); ok {
+		b.withPresetRef(typeDecl, ref, fieldPath)
+	}
+	// Apply Kong tags to the field's type declaration
+	typeDeclWithKong := b.withKongTagFields(typeDecl, field, fieldPath)
+	if raw, ok := field.Tag.Lookup("kong_default"); ok {
+		b.withJSONDefault(typeDeclWithKong, raw, fieldPath)
+	}
+	if b.oneOfDefaults {
+		withOneOfDefault(typeDeclWithKong)
+	}
+	if b.nullable && field.Type.Kind() == reflect.Ptr && typeDeclWithKong["required"] == false {
+		typeDeclWithKong["nullable"] = true
+	}
+	b.addScopeChecks(field, fieldPath)
+	b.checkTransforms(field, fieldPath)
+	groups.add(b, field, fieldPath)
+	return []schemaDict{{name: typeDeclWithKong}}
+}
+
+// flattened returns the struct type of an embedded field whose fields
+// encoding/json reads as the outer struct's: one not named by a json tag,
+// and, if a pointer, of an exported type (which json can allocate).
+func flattened(field reflect.StructField) (reflect.Type, bool) {
+	if name, _, _ := strings.Cut(field.Tag.Get("json"), ","); !field.Anonymous || name != "" {
+		return nil, false
+	}
+
+	t := derefType(field.Type)
+	if t.Kind() != reflect.Struct || (field.Type.Kind() == reflect.Ptr && !field.IsExported()) {
+		return nil, false
+	}
+	return t, true
+}
+
+// fieldName returns the name of a field in the configuration data.  That is
+// the one encoding/json would use, taken from the `json` tag, or the name
+// given in the `protobuf` tag of generated code.  Returns false for fields
+// that json skips, and for the XXX_ fields of old protobuf generated code.
+func fieldName(field reflect.StructField) (string, bool) {
+	if strings.HasPrefix(field.Name, "XXX_") {
+		return "", false
+	}
+
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" {
+		return "", false
+	}
+	if name != "" {
+		return name, true
+	}
+
+	for _, opt := range strings.Split(field.Tag.Get("protobuf"), ",") {
+		if pbName, ok := strings.CutPrefix(opt, "name="); ok {
+			return pbName, true
+		}
+	}
+
+	return strings.ToLower(field.Name), true
+}
+
+// recordDefault composes a default for a record out of its fields' defaults.
+// It's only possible if every field has one.
+func recordDefault(fields []schemaDict) (schemaDict, bool) {
+	if len(fields) == 0 {
+		return nil, false
+	}
+
+	def := schemaDict{}
+	for _, field := range fields {
+		for name, decl := range field {
+			value, ok := decl.(schemaDict)["default"]
+			if !ok {
+				return nil, false
+			}
+			def[name] = value
+		}
+	}
+
+	return def, true
+}
+
+// Keys Kong adds to the configuration data it sends to the plugin server.
+var reservedKeys = []string{"__key__", "__seq__", "__plugin_id"}
+
+// Fields of the plugin entity that define the scope it's attached to.
+var scopeFields = []string{"consumer", "consumer_group", "route", "service"}
+
+// addScopeChecks handles the `required_on` Kong tag, which makes a field
+// required only when the plugin is attached to the given scopes (separated
+// by ';'), for example `kong:"required_on=route;service"`.
+func (b *schemaBuilder) addScopeChecks(field reflect.StructField, path string) {
+	scopes, ok := kongTagValue(field, "required_on")
+	if !ok {
+		return
+	}
+
+	for _, scope := range strings.Split(scopes, ";") {
+		if !slices.Contains(scopeFields, scope) {
+			b.warnf("field %s: unknown plugin scope %q", path, scope)
+			continue
+		}
+		b.entityChecks = append(b.entityChecks, schemaDict{
+			"conditional": schemaDict{
+				"if_field":   scope,
+				"if_match":   schemaDict{"required": true},
+				"then_field": path,
+				"then_match": schemaDict{"required": true},
+			},
+		})
+	}
+}
+
+// Entity checks for each `group_policy` tag value.
+var groupPolicies = map[string]string{
+	"only_one":           "only_one_of",
+	"at_least_one":       "at_least_one_of",
+	"mutually_exclusive": "mutually_exclusive",
+}
+
+// A fieldGroup gathers the fields of a record sharing a `group` tag.
+type fieldGroup struct {
+	name   string
+	policy string
+	paths  []string
+}
+
+type fieldGroups []*fieldGroup
+
+// add handles the `group` and `group_policy` Kong tags, as in
+// `kong:"group=auth,group_policy=only_one"`.  The policy can be given
+// on any of the group's fields.
+func (groups *fieldGroups) add(b *schemaBuilder, field reflect.StructField, path string) {
+	name, ok := kongTagValue(field, "group")
+	if !ok {
+		return
+	}
+
+	idx := slices.IndexFunc(*groups, func(g *fieldGroup) bool { return g.name == name })
+	if idx < 0 {
+		*groups = append(*groups, &fieldGroup{name: name})
+		idx = len(*groups) - 1
+	}
+	group := (*groups)[idx]
+	group.paths = append(group.paths, path)
+
+	policy, ok := kongTagValue(field, "group_policy")
+	if !ok {
+		return
+	}
+	if group.policy != "" && group.policy != policy {
+		b.warnf("field %s: group %q already has policy %q", path, name, group.policy)
+		return
+	}
+	group.policy = policy
+}
+
+// addGroupChecks adds the entity check for each group's policy.
+func (b *schemaBuilder) addGroupChecks(groups fieldGroups) {
+	for _, group := range groups {
+		check, ok := groupPolicies[group.policy]
+		if !ok {
+			b.warnf("group %q: unknown group_policy %q", group.name, group.policy)
+			continue
+		}
+		b.entityChecks = append(b.entityChecks, schemaDict{check: group.paths})
+	}
+}
+
+// kongTagValue returns the value given to key in the field's Kong tag.
+func kongTagValue(field reflect.StructField, key string) (string, bool) {
+	for _, tag := range strings.Split(field.Tag.Get("kong"), ",") {
+		parts := strings.Split(tag, "=")
+		if len(parts) == 2 && parts[0] == key {
+			return parts[1], true
+		}
+	}
+
+	return "", false
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// Lua pattern (as used by Kong's `match`) approximating RFC 3339 timestamps.
+// Lua patterns lack alternation and optional groups, so the fractional
+// seconds and zone offset are matched loosely.
+const rfc3339Pattern = `^%d%d%d%d%-%d%d%-%d%d[Tt]%d%d:%d%d:%d%d[%.%d]*[Zz+%-][%d:]*$`
+
+// Declarations nested in a field's, whose values can be restricted with a
+// prefixed Kong tag key, as in `kong:"values.one_of=a;b"` on a map.
+var nestedDecls = []string{"keys", "values", "elements"}
+
+// Kong tag keys that don't translate directly into the field declaration.
+var otherTagFields = []string{"required_on", "format", "group", "group_policy", "len_unit", "unit", "err", "preset", "readonly", "transform", "flags", "preset_ref"}
+
+// withKongTagFields applies the field's Kong tag to its type declaration.
+// Unknown or malformed tag entries are reported as warnings.
+func (b *schemaBuilder) withKongTagFields(current schemaDict, field reflect.StructField, path string) schemaDict {
+	var validFields = []string{"required", "referenceable", "encrypted", "default", "match", "one_of", "between", "len_min", "len_max"}
+	var boolFields = []string{"required", "referenceable", "encrypted"}
+	var intFields = []string{"len_min", "len_max"}
+	var listFields = []string{"one_of", "between"}
+	result := current
+	tag := field.Tag.Get("kong")
+	if tag == "" {
+		return result
+	}
+
+	tagMap := strings.Split(tag, ",")
+	for _, tag := range tagMap {
+		parts := strings.Split(tag, "=")
+		if len(parts) != 2 {
+			b.warnf("field %s: ignoring malformed kong tag entry %q", path, tag)
+			continue
+		}
+		if slices.Contains(validFields, parts[0]) {
+			result[parts[0]] = parts[1]
+		} else if !slices.Contains(otherTagFields, parts[0]) && !strings.Contains(parts[0], ".") {
+			b.warnf("field %s: ignoring unknown kong tag key %q", path, parts[0])
+		}
+
+		if slices.Contains(boolFields, parts[0]) {
+			result[parts[0]] = parts[1] == "true"
+		}
+
+		if parts[0] == "format" {
+			if parts[1] != "rfc3339" || field.Type != timeType {
+				b.warnf("field %s: unsupported format %q", path, parts[1])
+				continue
+			}
+			result["match"] = rfc3339Pattern
+		}
+
+		if slices.Contains(intFields, parts[0]) {
+			n, err := strconv.Atoi(parts[1])
+			if err != nil {
+				b.warnf("field %s: %s must be an integer, got %q", path, parts[0], parts[1])
+				delete(result, parts[0])
+				continue
+			}
+			result[parts[0]] = n
+		}
+
+		if slices.Contains(listFields, parts[0]) {
+			// an array's values are restricted on its elements
+			target, valueType := result, field.Type
+			if elements, ok := result["elements"].(schemaDict); ok {
+				delete(result, parts[0])
+				target, valueType = elements, derefType(valueType).Elem()
+			}
+			labels, ok := b.withListTag(target, parts[0], parts[1], valueType, path)
+			if !ok {
+				delete(result, parts[0])
+			} else if labels != "" {
+				result["description"] = labels
+			}
+		}
+
+		if sub, key, ok := strings.Cut(parts[0], "."); ok && slices.Contains(nestedDecls, sub) {
+			nested, isDict := result[sub].(schemaDict)
+			if !isDict || !slices.Contains(listFields, key) {
+				b.warnf("field %s: ignoring kong tag key %q", path, parts[0])
+				continue
+			}
+			valueType := derefType(field.Type)
+			if sub == "keys" {
+				valueType = valueType.Key()
+			} else {
+				valueType = valueType.Elem()
+			}
+			if labels, ok := b.withListTag(nested, key, parts[1], valueType, path); ok && labels != "" {
+				result["description"] = labels
+			}
+		}
+	}
+
+	// only tags count: unsigned kinds come with a between of their own
+	if b.strict {
+		for _, prefix := range append([]string{""}, nestedDecls...) {
+			if prefix != "" {
+				prefix += "."
+			}
+			_, oneOf := kongTagValue(field, prefix+"one_of")
+			_, between := kongTagValue(field, prefix+"between")
+			if oneOf && between {
+				b.errorf("field %s: has both between and one_of, pick one", path)
+			}
+		}
+	}
+
+	if flags, ok := kongTagValue(field, "flags"); ok {
+		_, between := kongTagValue(field, "between")
+		b.withFlags(result, flags, between, path)
+	}
+
+	if value, _ := kongTagValue(field, "readonly"); value == "true" {
+		b.withReadonly(result, path)
+	}
+
+	if message, ok := kongTagValue(field, "err"); ok {
+		b.withErrMessage(result, message, path)
+	}
+
+	// Kong counts string lengths in bytes.  A string of n runes takes between
+	// n and n*utf8.UTFMax bytes, so only the maximum needs to be widened.
+	if unit, ok := kongTagValue(field, "len_unit"); ok {
+		switch unit {
+		case "rune":
+			if lenMax, ok := result["len_max"].(int); ok {
+				result["len_max"] = lenMax * utf8.UTFMax
+			}
+		case "byte":
+		default:
+			b.warnf("field %s: unknown len_unit %q", path, unit)
+		}
+	}
+
+	if b.deriveLenMax && result["type"] == "string" {
+		if _, ok := result["len_max"]; !ok {
+			if values, ok := result["one_of"].([]string); ok {
+				lenMax := 0
+				for _, v := range values {
+					lenMax = max(lenMax, len(v))
+				}
+				result["len_max"] = lenMax
+			}
+		}
+	}
+
+	if derefType(field.Type) == durationType {
+		b.withDurationUnit(result, field, path)
+	}
+
+	b.dropUnsupportedKeys(result, path)
+
+	return result
+}
+
+// withJSONDefault sets the default of a map field from the JSON object in
+// its `kong_default` tag (a tag of its own, as the JSON would need commas),
+// as in
+//
+//	Rules map[string]Rule `json:"rules" kong_default:"{\"main\":{\"limit\":10}}"`
+//
+// The default is checked against the declaration of the map's values.
+func (b *schemaBuilder) withJSONDefault(decl schemaDict, raw string, path string) {
+	if decl["type"] != "map" {
+		b.warnf("field %s: kong_default is only for maps", path)
+		return
+	}
+
+	var def map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &def); err != nil {
+		b.warnf("field %s: kong_default: %s", path, err)
+		return
+	}
+
+	v := validator{}
+	v.value(decl, def, "")
+	for _, result := range v.results {
+		b.warnf("field %s: kong_default: %s: %s", path, result.Path, result.Message)
+	}
+	if len(v.results) == 0 {
+		decl["default"] = def
+	}
+}
+
+// withOneOfDefault defaults a field restricted by one_of to its first
+// allowed value, unless it has a default already.
+func withOneOfDefault(decl schemaDict) {
+	if _, ok := decl["default"]; ok {
+		return
+	}
+	if values := reflect.ValueOf(decl["one_of"]); values.Kind() == reflect.Slice && values.Len() > 0 {
+		decl["default"] = values.Index(0).Interface()
+	}
+}
+
+// withReadonly makes a field accept no other value than its default, with
+// Kong's eq validator, so it can't be set through the Admin API.
+func (b *schemaBuilder) withReadonly(decl schemaDict, path string) {
+	def, ok := decl["default"].(string)
+	if !ok {
+		b.warnf("field %s: readonly needs a default, the only value it can take", path)
+		return
+	}
+
+	var value interface{} = def
+	switch decl["type"] {
+	case "boolean":
+		value = def == "true"
+	case "integer", "number":
+		values, err := tagList(decl, def)
+		if err != nil {
+			b.warnf("field %s: readonly: %s", path, err)
+			return
+		}
+		value = reflect.ValueOf(values).Index(0).Interface()
+	}
+	decl["eq"] = value
+}
+
+// nestedDecl returns the named declaration nested in decl, or an empty one.
+func nestedDecl(decl schemaDict, name string) schemaDict {
+	nested, _ := decl[name].(schemaDict)
+	return nested
+}
+
+// withErrMessage attaches the message from an `err` tag to the field's
+// validator.  Kong only takes custom messages for patterns, so a match is
+// turned into the equivalent match_all; one_of and between have no message,
+// which is shown in the field's description instead.
+func (b *schemaBuilder) withErrMessage(decl schemaDict, message string, path string) {
+	if pattern, ok := decl["match"]; ok {
+		delete(decl, "match")
+		decl["match_all"] = []schemaDict{{"pattern": pattern, "err": message}}
+		return
+	}
+
+	_, oneOf := decl["one_of"]
+	_, between := decl["between"]
+	if !oneOf && !between {
+		b.warnf("field %s: err needs a match, one_of or between validator", path)
+		return
+	}
+	if description, ok := decl["description"].(string); ok {
+		message = description + ". " + message
+	}
+	decl["description"] = message
+}
+
+// withListTag sets a list-valued tag entry (one_of or between) on decl,
+// the declaration of values of type t.  Returns a description of labelled
+// one_of values, and false if the tag entry is invalid.
+func (b *schemaBuilder) withListTag(decl schemaDict, key, value string, t reflect.Type, path string) (string, bool) {
+	list, labels := splitLabels(value)
+	values, err := tagList(decl, list)
+	if err != nil {
+		b.warnf("field %s: %s: %s", path, key, err)
+		return "", false
+	}
+	if key == "between" && reflect.ValueOf(values).Len() != 2 {
+		b.warnf("field %s: between needs two bounds, got %q", path, value)
+		return "", false
+	}
+	if bounds, ok := values.([]int); ok && key == "between" {
+		b.clampBounds(bounds, derefType(t), path)
+	}
+	// replaces any bound derived from the field's kind
+	decl[key] = values
+	if labels != "" {
+		// Kong has no labelled one_of, describe them instead
+		labels = "One of: " + labels
+	}
+
+	return labels, true
+}
+
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// clampBounds limits integer bounds to the values the Go type can hold, as
+// larger ones would pass Kong's validation but overflow when decoded.
+func (b *schemaBuilder) clampBounds(bounds []int, t reflect.Type, path string) {
+	var lo, hi int64
+	switch t.Kind() {
+	case reflect.Int32:
+		lo, hi = math.MinInt32, math.MaxInt32
+	case reflect.Uint32:
+		lo, hi = 0, math.MaxUint32
+	case reflect.Int:
+		if strconv.IntSize != 32 {
+			return
+		}
+		lo, hi = math.MinInt32, math.MaxInt32
+	case reflect.Uint:
+		lo, hi = 0, math.MaxInt64
+		if strconv.IntSize == 32 {
+			hi = math.MaxUint32
+		}
+	default:
+		return
+	}
+
+	for i, bound := range bounds {
+		clamped := min(max(int64(bound), lo), hi)
+		if clamped != int64(bound) {
+			b.warnf("field %s: between bound %d overflows %s, clamped to %d", path, bound, t, clamped)
+			bounds[i] = int(clamped)
+		}
+	}
+}
+
+// splitLabels takes the display labels out of a ';'-separated list of
+// "value:label" items, returning the bare values and a readable description
+// of the labels, empty if there are none.
+func splitLabels(value string) (string, string) {
+	items := strings.Split(value, ";")
+	labels := []string{}
+	for i, item := range items {
+		v, label, ok := strings.Cut(item, ":")
+		if !ok {
+			continue
+		}
+		items[i] = v
+		labels = append(labels, fmt.Sprintf("%s (%s)", v, label))
+	}
+
+	return strings.Join(items, ";"), strings.Join(labels, ", ")
+}
+
+// tagList splits a ';'-separated tag value, converting each element to match
+// the declared type.
+func tagList(decl schemaDict, value string) (interface{}, error) {
+	items := strings.Split(value, ";")
+
+	switch decl["type"] {
+	case "integer":
+		list := make([]int, len(items))
+		for i, item := range items {
+			n, err := strconv.Atoi(item)
+			if err != nil {
+				return nil, fmt.Errorf("%q is not an integer", item)
+			}
+			list[i] = n
+		}
+		return list, nil
+
+	case "number":
+		list := make([]float64, len(items))
+		for i, item := range items {
+			n, err := strconv.ParseFloat(item, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%q is not a number", item)
+			}
+			list[i] = n
+		}
+		return list, nil
+	}
+
+	return items, nil
+}
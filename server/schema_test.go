@@ -0,0 +1,774 @@
+package server
+
+import (
+	"encoding/json"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOneOfTag(t *testing.T) {
+	type Config struct {
+		Mode  string  `json:"mode" kong:"one_of=fast;balanced"`
+		Code  int     `json:"code" kong:"one_of=200;404"`
+		Ratio float64 `json:"ratio" kong:"one_of=0.5;1"`
+	}
+
+	schema := getSchemaDict(reflect.TypeOf(Config{}))
+	assert.Equal(t, schemaDict{
+		"type": "record",
+		"fields": []schemaDict{
+			{"mode": schemaDict{"type": "string", "one_of": []string{"fast", "balanced"}}},
+			{"code": schemaDict{"type": "integer", "one_of": []int{200, 404}}},
+			{"ratio": schemaDict{"type": "number", "one_of": []float64{0.5, 1}}},
+		},
+	}, schema)
+}
+
+func TestDerivedLenMax(t *testing.T) {
+	type Config struct {
+		Mode  string `json:"mode" kong:"one_of=fast;balanced"`
+		Short string `json:"short" kong:"one_of=fast;balanced,len_max=20"`
+	}
+
+	b := &schemaBuilder{}
+	schema := b.getSchemaDict(reflect.TypeOf(Config{}), "config")
+	assert.NotContains(t, schema["fields"].([]schemaDict)[0]["mode"], "len_max")
+
+	b = &schemaBuilder{schemaOptions: schemaOptions{deriveLenMax: true}}
+	schema = b.getSchemaDict(reflect.TypeOf(Config{}), "config")
+	assert.Equal(t, []schemaDict{
+		{"mode": schemaDict{"type": "string", "one_of": []string{"fast", "balanced"}, "len_max": 8}},
+		{"short": schemaDict{"type": "string", "one_of": []string{"fast", "balanced"}, "len_max": 20}},
+	}, schema["fields"])
+}
+
+func TestNamedMapType(t *testing.T) {
+	type Config struct {
+		Query url.Values `json:"query"`
+	}
+
+	schema := getSchemaDict(reflect.TypeOf(Config{}))
+	assert.Equal(t, []schemaDict{
+		{"query": schemaDict{
+			"type": "map",
+			"keys": schemaDict{"type": "string"},
+			"values": schemaDict{
+				"type":     "array",
+				"elements": schemaDict{"type": "string"},
+			},
+		}},
+	}, schema["fields"])
+}
+
+func TestRecordDefaults(t *testing.T) {
+	type Retry struct {
+		Attempts int    `json:"attempts" kong:"default=3"`
+		Backoff  string `json:"backoff" kong:"default=linear"`
+	}
+	type Config struct {
+		Retry Retry  `json:"retry"`
+		Host  string `json:"host"`
+	}
+
+	b := &schemaBuilder{}
+	schema := b.getSchemaDict(reflect.TypeOf(Config{}), "config")
+	assert.NotContains(t, schema["fields"].([]schemaDict)[0]["retry"], "default")
+
+	b = &schemaBuilder{schemaOptions: schemaOptions{recordDefaults: true}}
+	schema = b.getSchemaDict(reflect.TypeOf(Config{}), "config")
+	assert.Equal(t, schemaDict{"attempts": "3", "backoff": "linear"},
+		schema["fields"].([]schemaDict)[0]["retry"].(schemaDict)["default"])
+	assert.NotContains(t, schema, "default")
+}
+
+func TestSliceOneOf(t *testing.T) {
+	type Config struct {
+		Codes []int    `json:"codes" kong:"one_of=200;301;404"`
+		Modes []string `json:"modes" kong:"one_of=a;b"`
+	}
+
+	schema := getSchemaDict(reflect.TypeOf(Config{}))
+	assert.Equal(t, []schemaDict{
+		{"codes": schemaDict{
+			"type":     "array",
+			"elements": schemaDict{"type": "integer", "one_of": []int{200, 301, 404}},
+		}},
+		{"modes": schemaDict{
+			"type":     "array",
+			"elements": schemaDict{"type": "string", "one_of": []string{"a", "b"}},
+		}},
+	}, schema["fields"])
+}
+
+func TestPointerFieldsRequired(t *testing.T) {
+	type Config struct {
+		Optional *string `json:"optional"`
+		Required *string `json:"required" kong:"required=true"`
+		Plain    string  `json:"plain"`
+	}
+
+	schema := getSchemaDict(reflect.TypeOf(Config{}))
+	assert.Equal(t, []schemaDict{
+		{"optional": schemaDict{"type": "string", "required": false}},
+		{"required": schemaDict{"type": "string", "required": true}},
+		{"plain": schemaDict{"type": "string"}},
+	}, schema["fields"])
+}
+
+// luaPatternRegexp translates the subset of Lua patterns used in schemas
+// into a Go regular expression.
+func luaPatternRegexp(pattern string) *regexp.Regexp {
+	var re strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		if c == '%' && i+1 < len(pattern) {
+			i++
+			switch pattern[i] {
+			case 'd':
+				re.WriteString(`\d`)
+			default:
+				re.WriteString(regexp.QuoteMeta(string(pattern[i])))
+			}
+			continue
+		}
+		re.WriteByte(c)
+	}
+	return regexp.MustCompile(re.String())
+}
+
+func TestTimeFormat(t *testing.T) {
+	type Config struct {
+		Since time.Time `json:"since" kong:"format=rfc3339"`
+		Until time.Time `json:"until"`
+	}
+
+	schema := getSchemaDict(reflect.TypeOf(Config{}))
+	assert.Equal(t, []schemaDict{
+		{"since": schemaDict{"type": "string", "match": rfc3339Pattern}},
+		{"until": schemaDict{"type": "string"}},
+	}, schema["fields"])
+
+	match := luaPatternRegexp(rfc3339Pattern)
+	assert.True(t, match.MatchString("2024-05-01T12:30:00Z"))
+	assert.True(t, match.MatchString("2024-05-01T12:30:00.123+02:00"))
+	assert.False(t, match.MatchString("May 1st 2024"))
+	assert.False(t, match.MatchString("2024-05-01 12:30"))
+
+	var config Config
+	assert.NoError(t, json.Unmarshal([]byte(`{"since":"2024-05-01T12:30:00Z"}`), &config))
+	assert.Equal(t, 2024, config.Since.Year())
+}
+
+func TestOneOfLabels(t *testing.T) {
+	type Config struct {
+		Balancer string `json:"balancer" kong:"one_of=rr:Round Robin;lc:Least Connections"`
+		Code     int    `json:"code" kong:"one_of=200:OK;404"`
+	}
+
+	schema := getSchemaDict(reflect.TypeOf(Config{}))
+	assert.Equal(t, []schemaDict{
+		{"balancer": schemaDict{
+			"type":        "string",
+			"one_of":      []string{"rr", "lc"},
+			"description": "One of: rr (Round Robin), lc (Least Connections)",
+		}},
+		{"code": schemaDict{
+			"type":        "integer",
+			"one_of":      []int{200, 404},
+			"description": "One of: 200 (OK)",
+		}},
+	}, schema["fields"])
+}
+
+func TestReservedKeys(t *testing.T) {
+	type Config struct {
+		PluginId string `json:"__plugin_id"`
+		Name     string `json:"name"`
+	}
+
+	var b schemaBuilder
+	b.getSchemaDict(reflect.TypeOf(Config{}), "config")
+	assert.Equal(t, []string{`field config.__plugin_id: "__plugin_id" is reserved by Kong`}, b.warnings)
+}
+
+func TestBetweenTag(t *testing.T) {
+	type Config struct {
+		Port    uint32  `json:"port" kong:"between=1;65535"`
+		Workers uint32  `json:"workers"`
+		Ratio   float64 `json:"ratio" kong:"between=0;1"`
+		Bad     int     `json:"bad" kong:"between=1"`
+	}
+
+	var b schemaBuilder
+	schema := b.getSchemaDict(reflect.TypeOf(Config{}), "config")
+	assert.Equal(t, []schemaDict{
+		{"port": schemaDict{"type": "integer", "between": []int{1, 65535}}},
+		{"workers": schemaDict{"type": "integer", "between": []int{0, 2147483648}}},
+		{"ratio": schemaDict{"type": "number", "between": []float64{0, 1}}},
+		{"bad": schemaDict{"type": "integer"}},
+	}, schema["fields"])
+	assert.Equal(t, []string{`field config.bad: between needs two bounds, got "1"`}, b.warnings)
+}
+
+// Trimmed down output of protoc-gen-go, old and new versions mixed.
+type pbRule struct {
+	ApiKey               string   `protobuf:"bytes,1,opt,name=api_key,json=apiKey,proto3" json:"api_key,omitempty"`
+	MaxRetries           int32    `protobuf:"varint,2,opt,name=max_retries,json=maxRetries,proto3"`
+	Tags                 []string `protobuf:"bytes,3,rep,name=tags,proto3" json:"tags,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func TestProtobufStruct(t *testing.T) {
+	schema := getSchemaDict(reflect.TypeOf(pbRule{}))
+	assert.Equal(t, []schemaDict{
+		{"api_key": schemaDict{"type": "string"}},
+		{"max_retries": schemaDict{"type": "integer"}},
+		{"tags": schemaDict{"type": "array", "elements": schemaDict{"type": "string"}}},
+	}, schema["fields"])
+}
+
+func TestLenUnitRune(t *testing.T) {
+	type Config struct {
+		Greeting string `json:"greeting" kong:"len_min=2,len_max=10,len_unit=rune"`
+		Code     string `json:"code" kong:"len_max=10,len_unit=byte"`
+	}
+
+	schema := getSchemaDict(reflect.TypeOf(Config{}))
+	assert.Equal(t, []schemaDict{
+		{"greeting": schemaDict{"type": "string", "len_min": 2, "len_max": 40}},
+		{"code": schemaDict{"type": "string", "len_max": 10}},
+	}, schema["fields"])
+}
+
+func TestBetweenOverflow(t *testing.T) {
+	type Config struct {
+		Small  int32   `json:"small" kong:"between=0;99999999999"`
+		Counts []int32 `json:"counts" kong:"between=-99999999999;10"`
+		Big    int     `json:"big" kong:"between=0;99999999999"`
+		Count  uint    `json:"count" kong:"between=-5;99999999999"`
+	}
+
+	var b schemaBuilder
+	schema := b.getSchemaDict(reflect.TypeOf(Config{}), "config")
+	assert.Equal(t, []schemaDict{
+		{"small": schemaDict{"type": "integer", "between": []int{0, 2147483647}}},
+		{"counts": schemaDict{
+			"type":     "array",
+			"elements": schemaDict{"type": "integer", "between": []int{-2147483648, 10}},
+		}},
+		{"big": schemaDict{"type": "integer", "between": []int{0, 99999999999}}},
+		{"count": schemaDict{"type": "integer", "between": []int{0, 99999999999}}},
+	}, schema["fields"])
+	assert.Equal(t, []string{
+		"field config.small: between bound 99999999999 overflows int32, clamped to 2147483647",
+		"field config.counts: between bound -99999999999 overflows int32, clamped to -2147483648",
+		"field config.count: between bound -5 overflows uint, clamped to 0",
+	}, b.warnings)
+}
+
+type rawRule struct {
+	Expr string
+}
+
+func (r rawRule) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.Expr)
+}
+
+type describedRule struct {
+	rawRule
+}
+
+func (r *describedRule) KongSchema() map[string]interface{} {
+	return map[string]interface{}{"type": "string", "len_min": 1}
+}
+
+func TestJsonMarshalerSchema(t *testing.T) {
+	type Config struct {
+		Rule      rawRule        `json:"rule"`
+		Described *describedRule `json:"described"`
+	}
+
+	schema := getSchemaDict(reflect.TypeOf(Config{}))
+	assert.Equal(t, []schemaDict{
+		{"rule": schemaDict{"type": "json"}},
+		{"described": schemaDict{"type": "string", "len_min": 1, "required": false}},
+	}, schema["fields"])
+}
+
+func TestKongVersionKeys(t *testing.T) {
+	type Config struct {
+		Password string `json:"password" kong:"referenceable=true,required=true"`
+	}
+
+	rh := newRpcHandler(func() interface{} { return &Config{} }, "0.1", 1)
+	schema := rh.newSchemaBuilder().getSchemaDict(reflect.TypeOf(Config{}), "config")
+	assert.Equal(t, []schemaDict{
+		{"password": schemaDict{"type": "string", "referenceable": true, "required": true}},
+	}, schema["fields"])
+
+	rh = newRpcHandler(func() interface{} { return &Config{} }, "0.1", 1, WithKongVersion("3.4"))
+	schema = rh.newSchemaBuilder().getSchemaDict(reflect.TypeOf(Config{}), "config")
+	assert.Equal(t, []schemaDict{
+		{"password": schemaDict{"type": "string", "referenceable": true, "required": true}},
+	}, schema["fields"])
+
+	rh = newRpcHandler(func() interface{} { return &Config{} }, "0.1", 1, WithKongVersion("2.1.4"))
+	b := rh.newSchemaBuilder()
+	schema = b.getSchemaDict(reflect.TypeOf(Config{}), "config")
+	assert.Equal(t, []schemaDict{
+		{"password": schemaDict{"type": "string", "required": true}},
+	}, schema["fields"])
+	assert.Equal(t, []string{"field config.password: referenceable needs Kong 2.8.0, left out for 2.1.4"}, b.warnings)
+}
+
+func TestDurationUnit(t *testing.T) {
+	type Config struct {
+		Timeout  time.Duration  `json:"timeout" kong:"unit=s,default=30"`
+		Interval *time.Duration `json:"interval" kong:"unit=ms,default=1m"`
+		Raw      time.Duration  `json:"raw"`
+	}
+
+	schema := getSchemaDict(reflect.TypeOf(Config{}))
+	assert.Equal(t, []schemaDict{
+		{"timeout": schemaDict{"type": "integer", "default": 30}},
+		{"interval": schemaDict{"type": "integer", "default": 60000, "required": false}},
+		{"raw": schemaDict{"type": "integer"}},
+	}, schema["fields"])
+
+	rh := newRpcHandler(func() interface{} { return &Config{} }, "0.1", 1)
+	var status InstanceStatus
+	assert.NoError(t, rh.StartInstance(PluginConfig{Name: "test", Config: []byte(`{"timeout":30,"interval":250,"raw":5}`)}, &status))
+	config := status.Config.(*Config)
+	assert.Equal(t, 30*time.Second, config.Timeout)
+	assert.Equal(t, 250*time.Millisecond, *config.Interval)
+	assert.Equal(t, 5*time.Nanosecond, config.Raw)
+}
+
+func TestDurationUnitInRecords(t *testing.T) {
+	type Rule struct {
+		Timeout time.Duration `json:"timeout" kong:"unit=s"`
+	}
+	type Config struct {
+		Rules  []Rule          `json:"rules"`
+		Routes map[string]Rule `json:"routes"`
+		Fixed  [1]*Rule        `json:"fixed"`
+	}
+
+	rh := newRpcHandler(func() interface{} { return &Config{} }, "0.1", 1)
+	var status InstanceStatus
+	assert.NoError(t, rh.StartInstance(PluginConfig{Name: "test",
+		Config: []byte(`{"rules":[{"timeout":30},{"timeout":5}],"routes":{"a":{"timeout":2}},"fixed":[{"timeout":1}]}`)}, &status))
+	config := status.Config.(*Config)
+	assert.Equal(t, []Rule{{30 * time.Second}, {5 * time.Second}}, config.Rules)
+	assert.Equal(t, map[string]Rule{"a": {2 * time.Second}}, config.Routes)
+	assert.Equal(t, time.Second, config.Fixed[0].Timeout)
+}
+
+func TestNestedTagKeys(t *testing.T) {
+	type Config struct {
+		Modes   map[string]string `json:"modes" kong:"values.one_of=a;b;c"`
+		Weights map[string]int    `json:"weights" kong:"keys.one_of=x;y,values.between=0;10"`
+		Tags    []string          `json:"tags" kong:"elements.one_of=v1:first;v2"`
+		Name    string            `json:"name" kong:"values.one_of=a"`
+	}
+
+	b := &schemaBuilder{}
+	schema := b.getSchemaDict(reflect.TypeOf(Config{}), "config")
+	assert.Equal(t, []schemaDict{
+		{"modes": schemaDict{
+			"type":   "map",
+			"keys":   schemaDict{"type": "string"},
+			"values": schemaDict{"type": "string", "one_of": []string{"a", "b", "c"}},
+		}},
+		{"weights": schemaDict{
+			"type":   "map",
+			"keys":   schemaDict{"type": "string", "one_of": []string{"x", "y"}},
+			"values": schemaDict{"type": "integer", "between": []int{0, 10}},
+		}},
+		{"tags": schemaDict{
+			"type":        "array",
+			"elements":    schemaDict{"type": "string", "one_of": []string{"v1", "v2"}},
+			"description": "One of: v1 (first)",
+		}},
+		{"name": schemaDict{"type": "string"}},
+	}, schema["fields"])
+	assert.Equal(t, []string{`field config.name: ignoring kong tag key "values.one_of"`}, b.warnings)
+}
+
+func TestErrMessage(t *testing.T) {
+	type Config struct {
+		Id    string    `json:"id" kong:"match=^x+$,err=must be made of x"`
+		Mode  string    `json:"mode" kong:"one_of=a:auto;m,err=pick a mode"`
+		Port  int       `json:"port" kong:"between=1;65535,err=not a port"`
+		Stamp time.Time `json:"stamp" kong:"format=rfc3339,err=not a timestamp"`
+		Plain string    `json:"plain" kong:"err=oops"`
+	}
+
+	b := &schemaBuilder{}
+	schema := b.getSchemaDict(reflect.TypeOf(Config{}), "config")
+	assert.Equal(t, []schemaDict{
+		{"id": schemaDict{"type": "string", "match_all": []schemaDict{{"pattern": "^x+$", "err": "must be made of x"}}}},
+		{"mode": schemaDict{"type": "string", "one_of": []string{"a", "m"}, "description": "One of: a (auto). pick a mode"}},
+		{"port": schemaDict{"type": "integer", "between": []int{1, 65535}, "description": "not a port"}},
+		{"stamp": schemaDict{"type": "string", "match_all": []schemaDict{{"pattern": rfc3339Pattern, "err": "not a timestamp"}}}},
+		{"plain": schemaDict{"type": "string"}},
+	}, schema["fields"])
+	assert.Equal(t, []string{"field config.plain: err needs a match, one_of or between validator"}, b.warnings)
+}
+
+func TestStrictSchema(t *testing.T) {
+	type Config struct {
+		Port  int            `json:"port" kong:"between=1;65535,one_of=80;443"`
+		Codes []int          `json:"codes" kong:"between=100;599,one_of=200;404"`
+		Size  int            `json:"size" kong:"between=1;10"`
+		Code  uint           `json:"code" kong:"one_of=1;2"`
+		Rates map[string]int `json:"rates" kong:"values.between=1;10,values.one_of=5;6"`
+	}
+	constructor := func() interface{} { return &Config{} }
+
+	rh := newRpcHandler(constructor, "0.1", 1)
+	assert.NotNil(t, rh)
+	assert.NoError(t, rh.checkSchema())
+
+	rh.schemaOptions.strict = true
+	assert.EqualError(t, rh.checkSchema(),
+		"field config.port: has both between and one_of, pick one\n"+
+			"field config.codes: has both between and one_of, pick one\n"+
+			"field config.rates: has both between and one_of, pick one")
+	assert.Nil(t, newRpcHandler(constructor, "0.1", 1, WithStrictSchema()))
+}
+
+func TestRequiredMarker(t *testing.T) {
+	type Config struct {
+		Host    Required[string]  `json:"host"`
+		Ports   Required[[]int]   `json:"ports"`
+		Timeout Required[int]     `json:"timeout" kong:"default=10"`
+		Name    string            `json:"name"`
+		Token   *Required[string] `json:"token"`
+	}
+
+	schema := getSchemaDict(reflect.TypeOf(Config{}))
+	assert.Equal(t, []schemaDict{
+		{"host": schemaDict{"type": "string", "required": true}},
+		{"ports": schemaDict{"type": "array", "elements": schemaDict{"type": "integer"}, "required": true}},
+		{"timeout": schemaDict{"type": "integer", "required": true, "default": "10"}},
+		{"name": schemaDict{"type": "string"}},
+		{"token": schemaDict{"type": "string", "required": true}},
+	}, schema["fields"])
+	assert.NotNil(t, newRpcHandler(func() interface{} { return &Config{} }, "0.1", 1))
+
+	var config Config
+	assert.NoError(t, json.Unmarshal([]byte(`{"host":"example.com","ports":[80,443],"timeout":5,"token":"t"}`), &config))
+	assert.Equal(t, "example.com", config.Host.Value)
+	assert.Equal(t, []int{80, 443}, config.Ports.Value)
+	assert.Equal(t, 5, config.Timeout.Value)
+	assert.Equal(t, "t", config.Token.Value)
+}
+
+type logLevel string
+
+func (logLevel) Values() []string {
+	return []string{"debug", "info", "warn"}
+}
+
+func TestEnumValues(t *testing.T) {
+	type Config struct {
+		Level      logLevel   `json:"level"`
+		Levels     []logLevel `json:"levels"`
+		Overridden logLevel   `json:"overridden" kong:"one_of=info"`
+	}
+
+	schema := getSchemaDict(reflect.TypeOf(Config{}))
+	assert.Equal(t, []schemaDict{
+		{"level": schemaDict{"type": "string", "one_of": []string{"debug", "info", "warn"}}},
+		{"levels": schemaDict{"type": "array", "elements": schemaDict{"type": "string", "one_of": []string{"debug", "info", "warn"}}}},
+		{"overridden": schemaDict{"type": "string", "one_of": []string{"info"}}},
+	}, schema["fields"])
+}
+
+type CommonOptions struct {
+	Timeout int    `json:"timeout" kong:"default=10"`
+	Region  string `json:"region"`
+}
+
+type commonNames struct {
+	Label string `json:"label"`
+}
+
+func TestEmbeddedStructs(t *testing.T) {
+	type Config struct {
+		*CommonOptions
+		commonNames
+		Name string `json:"name"`
+	}
+
+	schema := getSchemaDict(reflect.TypeOf(Config{}))
+	assert.Equal(t, []schemaDict{
+		{"timeout": schemaDict{"type": "integer", "default": "10"}},
+		{"region": schemaDict{"type": "string"}},
+		{"label": schemaDict{"type": "string"}},
+		{"name": schemaDict{"type": "string"}},
+	}, schema["fields"])
+
+	rh := newRpcHandler(func() interface{} { return &Config{} }, "0.1", 1)
+	var status InstanceStatus
+	assert.NoError(t, rh.StartInstance(PluginConfig{Name: "test", Config: []byte(`{"name":"n","label":"l"}`)}, &status))
+	config := status.Config.(*Config)
+	assert.Nil(t, config.CommonOptions)
+	assert.Equal(t, "l", config.Label)
+
+	assert.NoError(t, rh.StartInstance(PluginConfig{Name: "test", Config: []byte(`{"timeout":5,"region":"eu"}`)}, &status))
+	config = status.Config.(*Config)
+	assert.Equal(t, &CommonOptions{Timeout: 5, Region: "eu"}, config.CommonOptions)
+}
+
+func TestReadonly(t *testing.T) {
+	type Config struct {
+		Version string `json:"version" kong:"readonly=true,default=v2"`
+		Shards  int    `json:"shards" kong:"default=4,readonly=true"`
+		Enabled bool   `json:"enabled" kong:"readonly=true,default=true"`
+		Missing string `json:"missing" kong:"readonly=true"`
+	}
+
+	b := &schemaBuilder{}
+	schema := b.getSchemaDict(reflect.TypeOf(Config{}), "config")
+	assert.Equal(t, []schemaDict{
+		{"version": schemaDict{"type": "string", "default": "v2", "eq": "v2"}},
+		{"shards": schemaDict{"type": "integer", "default": "4", "eq": 4}},
+		{"enabled": schemaDict{"type": "boolean", "default": "true", "eq": true}},
+		{"missing": schemaDict{"type": "string"}},
+	}, schema["fields"])
+	assert.Equal(t, []string{"field config.missing: readonly needs a default, the only value it can take"}, b.warnings)
+}
+
+type stringy struct {
+	parts []string
+}
+
+func (s stringy) MarshalText() ([]byte, error) {
+	return []byte(strings.Join(s.parts, "/")), nil
+}
+
+func (s *stringy) UnmarshalText(text []byte) error {
+	s.parts = strings.Split(string(text), "/")
+	return nil
+}
+
+func TestTextMarshalerSlice(t *testing.T) {
+	type Config struct {
+		Path  stringy   `json:"path"`
+		Paths []stringy `json:"paths"`
+		Addrs []net.IP  `json:"addrs"`
+	}
+
+	schema := getSchemaDict(reflect.TypeOf(Config{}))
+	assert.Equal(t, []schemaDict{
+		{"path": schemaDict{"type": "string"}},
+		{"paths": schemaDict{"type": "array", "elements": schemaDict{"type": "string"}}},
+		{"addrs": schemaDict{"type": "array", "elements": schemaDict{"type": "string"}}},
+	}, schema["fields"])
+}
+
+func TestMapJSONDefault(t *testing.T) {
+	type Rule struct {
+		Limit  int    `json:"limit" kong:"required=true,between=1;100"`
+		Policy string `json:"policy" kong:"one_of=local;cluster"`
+	}
+	type Config struct {
+		Rules map[string]Rule `json:"rules" kong_default:"{\"main\":{\"limit\":10,\"policy\":\"local\"}}"`
+		Bad   map[string]Rule `json:"bad" kong_default:"{\"main\":{\"policy\":\"global\"}}"`
+		Name  string          `json:"name" kong_default:"{}"`
+	}
+
+	b := &schemaBuilder{}
+	schema := b.getSchemaDict(reflect.TypeOf(Config{}), "config")
+	fields := schema["fields"].([]schemaDict)
+	assert.Equal(t, map[string]interface{}{
+		"main": map[string]interface{}{"limit": 10.0, "policy": "local"},
+	}, fields[0]["rules"].(schemaDict)["default"])
+	assert.NotContains(t, fields[1]["bad"], "default")
+	assert.Equal(t, []string{
+		"field config.bad: kong_default: main.limit: required field missing",
+		"field config.bad: kong_default: main.policy: expected one of: local, cluster",
+		"field config.name: kong_default is only for maps",
+	}, b.warnings)
+}
+
+func TestRegexpField(t *testing.T) {
+	type Config struct {
+		Pattern *regexp.Regexp `json:"pattern"`
+	}
+
+	schema := getSchemaDict(reflect.TypeOf(Config{}))
+	assert.Equal(t, []schemaDict{
+		{"pattern": schemaDict{"type": "string", "required": false}},
+	}, schema["fields"])
+
+	rh := newRpcHandler(func() interface{} { return &Config{} }, "0.1", 1)
+	var status InstanceStatus
+	assert.NoError(t, rh.StartInstance(PluginConfig{Name: "test", Config: []byte(`{"pattern":"^/api/v[0-9]+"}`)}, &status))
+	assert.True(t, status.Config.(*Config).Pattern.MatchString("/api/v2/users"))
+
+	err := rh.StartInstance(PluginConfig{Name: "test", Config: []byte(`{"pattern":"^/api/(v"}`)}, &status)
+	assert.EqualError(t, err, "decoding config: error parsing regexp: missing closing ): `^/api/(v`")
+}
+
+type genericLimit[T any] struct {
+	Value    T            `json:"value" kong:"required=true"`
+	Fallback []T          `json:"fallback"`
+	ByRoute  map[string]T `json:"by_route"`
+}
+
+type genericConfig[T any] struct {
+	Limit   genericLimit[T]  `json:"limit"`
+	Default *genericLimit[T] `json:"default"`
+}
+
+func TestGenericConfig(t *testing.T) {
+	limit := func(typ string) []schemaDict {
+		return []schemaDict{
+			{"value": schemaDict{"type": typ, "required": true}},
+			{"fallback": schemaDict{"type": "array", "elements": schemaDict{"type": typ}}},
+			{"by_route": schemaDict{"type": "map", "keys": schemaDict{"type": "string"}, "values": schemaDict{"type": typ}}},
+		}
+	}
+
+	for typ, config := range map[string]interface{}{
+		"integer": genericConfig[int]{},
+		"string":  genericConfig[string]{},
+	} {
+		schema := getSchemaDict(reflect.TypeOf(config))
+		assert.Equal(t, []schemaDict{
+			{"limit": schemaDict{"type": "record", "fields": limit(typ)}},
+			{"default": schemaDict{"type": "record", "fields": limit(typ), "required": false}},
+		}, schema["fields"], typ)
+	}
+}
+
+func TestOneOfDefaults(t *testing.T) {
+	type Config struct {
+		Mode  string   `json:"mode" kong:"one_of=fast;balanced"`
+		Code  int      `json:"code" kong:"one_of=200;404,default=404"`
+		Level logLevel `json:"level"`
+		Name  string   `json:"name"`
+	}
+
+	schema := getSchemaDict(reflect.TypeOf(Config{}))
+	assert.NotContains(t, schema["fields"].([]schemaDict)[0]["mode"], "default")
+
+	b := &schemaBuilder{schemaOptions: schemaOptions{oneOfDefaults: true}}
+	schema = b.getSchemaDict(reflect.TypeOf(Config{}), "config")
+	assert.Equal(t, []schemaDict{
+		{"mode": schemaDict{"type": "string", "one_of": []string{"fast", "balanced"}, "default": "fast"}},
+		{"code": schemaDict{"type": "integer", "one_of": []int{200, 404}, "default": "404"}},
+		{"level": schemaDict{"type": "string", "one_of": []string{"debug", "info", "warn"}, "default": "debug"}},
+		{"name": schemaDict{"type": "string"}},
+	}, schema["fields"])
+}
+
+func TestInterfaceFields(t *testing.T) {
+	type Config struct {
+		Extra  interface{}            `json:"extra"`
+		Labels map[string]interface{} `json:"labels"`
+		Err    error                  `json:"err"`
+		Name   string                 `json:"name"`
+	}
+
+	b := &schemaBuilder{}
+	schema := b.getSchemaDict(reflect.TypeOf(Config{}), "config")
+	assert.Equal(t, []schemaDict{
+		{"extra": schemaDict{"type": "json"}},
+		{"labels": schemaDict{"type": "map", "keys": schemaDict{"type": "string"}, "values": schemaDict{"type": "json"}}},
+		{"name": schemaDict{"type": "string"}},
+	}, schema["fields"])
+	assert.Equal(t, []string{
+		"field config.err: interface type error can't be decoded from the config, leaving it out",
+	}, b.warnings)
+}
+
+func TestNullablePointers(t *testing.T) {
+	type Config struct {
+		Limit    *int   `json:"limit"`
+		Required *int   `json:"required" kong:"required=true"`
+		Name     string `json:"name"`
+	}
+
+	schema := getSchemaDict(reflect.TypeOf(Config{}))
+	assert.NotContains(t, schema["fields"].([]schemaDict)[0]["limit"], "nullable")
+
+	b := &schemaBuilder{schemaOptions: schemaOptions{nullable: true}}
+	schema = b.getSchemaDict(reflect.TypeOf(Config{}), "config")
+	assert.Equal(t, []schemaDict{
+		{"limit": schemaDict{"type": "integer", "required": false, "nullable": true}},
+		{"required": schemaDict{"type": "integer", "required": true}},
+		{"name": schemaDict{"type": "string"}},
+	}, schema["fields"])
+
+	constructor := func() interface{} { return &Config{} }
+	description, err := Describe(constructor, "0.1", 1, WithName("test"), WithNullablePointers())
+	assert.NoError(t, err)
+	config := description.Schema["fields"].([]schemaDict)[1]["config"].(schemaDict)
+	assert.Equal(t, true, config["fields"].([]schemaDict)[0]["limit"].(schemaDict)["nullable"])
+
+	// what Kong gets has no nullable key, which it would reject
+	rh := newRpcHandler(constructor, "0.1", 1, WithName("test"), WithNullablePointers())
+	info, err := rh.getInfo()
+	assert.NoError(t, err)
+	problems, err := AssertKongCompatible(info.Schema, "3.9")
+	assert.NoError(t, err)
+	assert.Empty(t, problems)
+	problems, err = AssertKongCompatible(description.Schema, "3.9")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{`config.limit: "nullable" isn't a Kong field attribute`}, problems)
+
+	var status InstanceStatus
+	for _, data := range []string{`{"required":1}`, `{"required":1,"limit":null}`} {
+		assert.NoError(t, rh.StartInstance(PluginConfig{Name: "test", Config: []byte(data)}, &status))
+		assert.Nil(t, status.Config.(*Config).Limit, data)
+	}
+
+	assert.NoError(t, rh.StartInstance(PluginConfig{Name: "test", Config: []byte(`{"required":1,"limit":0}`)}, &status))
+	if assert.NotNil(t, status.Config.(*Config).Limit) {
+		assert.Equal(t, 0, *status.Config.(*Config).Limit)
+	}
+}
+
+type panickySchema struct{}
+
+func (panickySchema) KongSchema() map[string]interface{} {
+	panic("no schema today")
+}
+
+func TestFieldPanicContext(t *testing.T) {
+	type Inner struct {
+		Fine   string        `json:"fine"`
+		Broken panickySchema `json:"broken"`
+	}
+	type Config struct {
+		Name  string `json:"name"`
+		Inner Inner  `json:"inner"`
+	}
+
+	b := &schemaBuilder{}
+	schema := b.getSchemaDict(reflect.TypeOf(Config{}), "config")
+	assert.Equal(t, []schemaDict{
+		{"name": schemaDict{"type": "string"}},
+		{"inner": schemaDict{"type": "record", "fields": []schemaDict{
+			{"fine": schemaDict{"type": "string"}},
+		}}},
+	}, schema["fields"])
+	if assert.Len(t, b.errs, 1) {
+		assert.EqualError(t, b.errs[0], "field config.inner.broken: panic generating its schema: no schema today")
+	}
+
+	_, err := Describe(func() interface{} { return &Config{} }, "0.1", 1)
+	assert.EqualError(t, err, "field config.inner.broken: panic generating its schema: no schema today")
+}
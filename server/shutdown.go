@@ -0,0 +1,61 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+const defaultShutdownTimeout = 5 * time.Second
+
+type closer interface{ Close() error }
+
+// shutdown stops the worker pool, if any, closes every instance, running
+// their Close hook, and then the function given by WithOnShutdown, so
+// plugins can flush any pending state.
+// Gives up after the timeout set by WithShutdownTimeout.
+func (rh *rpcHandler) shutdown() error {
+	rh.lock.Lock()
+	instances := make([]*instanceData, 0, len(rh.instances))
+	for _, instance := range rh.instances {
+		instances = append(instances, instance)
+	}
+	rh.instances = map[int]*instanceData{}
+	rh.latestInstances = map[string]*instanceData{}
+	rh.lock.Unlock()
+
+	done := make(chan error, 1)
+	go func() {
+		var errs []error
+		if rh.pool != nil {
+			rh.pool.stop()
+		}
+		for _, instance := range instances {
+			if c, ok := instance.config.(closer); ok {
+				if err := c.Close(); err != nil {
+					errs = append(errs, fmt.Errorf("closing instance %d: %w", instance.id, err))
+				}
+			}
+		}
+		if rh.onShutdown != nil {
+			if err := rh.onShutdown(); err != nil {
+				errs = append(errs, fmt.Errorf("shutdown hook: %w", err))
+			}
+		}
+		done <- errors.Join(errs...)
+	}()
+
+	timeout := rh.shutdownTimeout
+	if timeout == 0 {
+		timeout = defaultShutdownTimeout
+	}
+
+	select {
+	case err := <-done:
+		log.Printf("closed %d instances", len(instances))
+		return err
+	case <-time.After(timeout):
+		return fmt.Errorf("shutdown timed out after %s", timeout)
+	}
+}
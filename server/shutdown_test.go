@@ -0,0 +1,49 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type closingConfig struct {
+	closed *int
+	delay  time.Duration
+}
+
+func (c *closingConfig) Close() error {
+	time.Sleep(c.delay)
+	*c.closed++
+	return nil
+}
+
+func TestShutdown(t *testing.T) {
+	closed := 0
+	hookCalled := false
+	rh := newRpcHandler(func() interface{} { return &closingConfig{closed: &closed} }, "0.1", 1,
+		WithOnShutdown(func() error {
+			hookCalled = true
+			return nil
+		}))
+
+	var status InstanceStatus
+	assert.NoError(t, rh.StartInstance(PluginConfig{Name: "test", Config: []byte(`{"__seq__":1}`)}, &status))
+	assert.NoError(t, rh.StartInstance(PluginConfig{Name: "test", Config: []byte(`{"__seq__":2}`)}, &status))
+
+	assert.NoError(t, rh.shutdown())
+	assert.Equal(t, 2, closed)
+	assert.True(t, hookCalled)
+	assert.Empty(t, rh.instances)
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	closed := 0
+	rh := newRpcHandler(func() interface{} { return &closingConfig{closed: &closed, delay: time.Second} }, "0.1", 1,
+		WithShutdownTimeout(10*time.Millisecond))
+
+	var status InstanceStatus
+	assert.NoError(t, rh.StartInstance(PluginConfig{Name: "test", Config: []byte(`{"__seq__":1}`)}, &status))
+
+	assert.ErrorContains(t, rh.shutdown(), "timed out")
+}
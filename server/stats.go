@@ -0,0 +1,67 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Details of a plugin instance, for debugging.
+type InstanceStats struct {
+	Id         int    // instance id
+	Key        string // plugin key, as sent by Kong
+	Revision   int    // 1 for the first instance of the plugin key, incremented for each replacement; always 1 without a key
+	ConfigHash string // SHA-256 of the configuration data, without Kong's metadata
+	StartTime  int64
+}
+
+// configHash returns the hex SHA-256 of configuration data, leaving out the
+// metadata fields (those named "__xxx__") and ignoring formatting and
+// key order, so identical configurations hash the same.
+func configHash(data []byte) (string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var config map[string]interface{}
+	if err := decoder.Decode(&config); err != nil {
+		return "", err
+	}
+	for k := range config {
+		if strings.HasPrefix(k, "__") {
+			delete(config, k)
+		}
+	}
+
+	canonical, err := json.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// InstanceStats returns the details of a plugin instance.
+//
+// RPC exported method
+func (rh *rpcHandler) InstanceStats(id int, stats *InstanceStats) error {
+	rh.lock.RLock()
+	defer rh.lock.RUnlock()
+
+	instance, ok := rh.instances[id]
+	if !ok {
+		return fmt.Errorf("no plugin instance %d", id)
+	}
+
+	*stats = InstanceStats{
+		Id:         instance.id,
+		Key:        instance.configMeta.Key,
+		Revision:   instance.revision,
+		ConfigHash: instance.configHash,
+		StartTime:  instance.startTime.Unix(),
+	}
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstanceRevisions(t *testing.T) {
+	type Config struct {
+		Limit int `json:"limit"`
+	}
+	rh := newRpcHandler(func() interface{} { return &Config{} }, "0.1", 1)
+
+	start := func(config string) InstanceStats {
+		var status InstanceStatus
+		assert.NoError(t, rh.StartInstance(PluginConfig{Name: "test", Config: []byte(config)}, &status))
+		var stats InstanceStats
+		assert.NoError(t, rh.InstanceStats(status.Id, &stats))
+		return stats
+	}
+
+	first := start(`{"__seq__":1,"__key__":"k","limit":10}`)
+	assert.Equal(t, 1, first.Revision)
+	assert.Equal(t, "k", first.Key)
+	assert.Len(t, first.ConfigHash, 64)
+
+	second := start(`{"__seq__":2,"__key__":"k","limit":20}`)
+	assert.Equal(t, 2, second.Revision)
+	assert.NotEqual(t, first.ConfigHash, second.ConfigHash)
+
+	// the same config, recreated, hashes the same whatever its formatting
+	third := start(`{ "limit": 10, "__key__": "k", "__seq__": 3 }`)
+	assert.Equal(t, 3, third.Revision)
+	assert.Equal(t, first.ConfigHash, third.ConfigHash)
+
+	// revisions are counted by plugin key
+	assert.Equal(t, 1, start(`{"__seq__":4,"__key__":"other","limit":10}`).Revision)
+
+	var stats InstanceStats
+	assert.EqualError(t, rh.InstanceStats(5, &stats), "no plugin instance 5")
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Kong/go-pdk"
+)
+
+// Stores of requests that never reach the plugin's last phase are dropped
+// after this.
+const requestStoreTimeout = 5 * time.Minute
+
+// A RequestStore holds values shared by the phases of a single request.
+// Unlike kong.Ctx, it lives in the plugin process, so values can be of any
+// Go type and don't need a call to Kong.
+type RequestStore struct {
+	lock    sync.Mutex
+	values  map[string]interface{}
+	created time.Time
+}
+
+// Get returns the value stored under key, if any.
+func (s *RequestStore) Get(key string) (interface{}, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	v, ok := s.values[key]
+	return v, ok
+}
+
+// Set stores a value under key.
+func (s *RequestStore) Set(key string, value interface{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.values[key] = value
+}
+
+// Delete removes the value stored under key.
+func (s *RequestStore) Delete(key string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.values, key)
+}
+
+type storeRegistry struct {
+	lock       sync.Mutex
+	stores     map[string]*RequestStore // by request id
+	events     map[*pdk.PDK]string      // request id of events that used a store
+	lastExpire time.Time
+}
+
+var requestStores = storeRegistry{
+	stores: map[string]*RequestStore{},
+	events: map[*pdk.PDK]string{},
+}
+
+// GetRequestStore returns the store of the request being handled.
+// Requests are told apart by the Nginx $request_id variable, so the first
+// call on each phase takes a call to Kong.  The store is dropped after the
+// last phase the plugin handles for the request (log, if it has a Log
+// handler), or else after a few minutes.
+func GetRequestStore(kong *pdk.PDK) (*RequestStore, error) {
+	requestStores.lock.Lock()
+	id, ok := requestStores.events[kong]
+	requestStores.lock.Unlock()
+
+	if !ok {
+		var err error
+		id, err = kong.Nginx.GetVar("request_id")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return requestStores.get(kong, id), nil
+}
+
+func (r *storeRegistry) get(kong *pdk.PDK, id string) *RequestStore {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.events[kong] = id
+	store, ok := r.stores[id]
+	if !ok {
+		r.expire()
+		store = &RequestStore{values: map[string]interface{}{}, created: time.Now()}
+		r.stores[id] = store
+	}
+
+	return store
+}
+
+// endEvent forgets the event, and its request's store after the last phase.
+func (r *storeRegistry) endEvent(kong *pdk.PDK, lastPhase bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	id, ok := r.events[kong]
+	if !ok {
+		return
+	}
+	delete(r.events, kong)
+	if lastPhase {
+		delete(r.stores, id)
+	}
+}
+
+// expire drops old stores, and the events that used them, at most once a
+// minute.  Must hold the lock.
+func (r *storeRegistry) expire() {
+	if time.Since(r.lastExpire) < time.Minute {
+		return
+	}
+	r.lastExpire = time.Now()
+
+	cutoff := time.Now().Add(-requestStoreTimeout)
+	for id, store := range r.stores {
+		if store.created.Before(cutoff) {
+			delete(r.stores, id)
+		}
+	}
+	for kong, id := range r.events {
+		if _, ok := r.stores[id]; !ok {
+			delete(r.events, kong)
+		}
+	}
+}
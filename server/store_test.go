@@ -0,0 +1,133 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Kong/go-pdk"
+	"github.com/Kong/go-pdk/bridge"
+	"github.com/Kong/go-pdk/bridge/bridgetest"
+	"github.com/stretchr/testify/assert"
+)
+
+type storeConfig struct {
+	logged *interface{}
+}
+
+func (c storeConfig) Access(kong *pdk.PDK) error {
+	store, err := GetRequestStore(kong)
+	if err != nil {
+		return err
+	}
+	store.Set("start", 42)
+	return nil
+}
+
+func (c storeConfig) Log(kong *pdk.PDK) error {
+	store, err := GetRequestStore(kong)
+	if err != nil {
+		return err
+	}
+	*c.logged, _ = store.Get("start")
+	return nil
+}
+
+func mockKong(t *testing.T, requestId string) *pdk.PDK {
+	return pdk.Init(bridgetest.Mock(t, []bridgetest.MockStep{
+		{Method: "kong.nginx.get_var", Args: bridge.WrapString("request_id"), Ret: bridge.WrapString(requestId)},
+	}))
+}
+
+func TestRequestStore(t *testing.T) {
+	var logged interface{}
+	rh := newRpcHandler(func() interface{} { return &storeConfig{logged: &logged} }, "0.1", 1)
+
+	var status InstanceStatus
+	assert.NoError(t, rh.StartInstance(PluginConfig{Name: "test", Config: []byte(`{"__seq__":1}`)}, &status))
+	handlers := rh.instances[status.Id].handlers
+
+	assert.NoError(t, rh.handleEvent("access", handlers["access"], nil, mockKong(t, "req-1")))
+	assert.NoError(t, rh.handleEvent("access", handlers["access"], nil, mockKong(t, "req-2")))
+	assert.Len(t, requestStores.stores, 2)
+	t.Cleanup(func() { delete(requestStores.stores, "req-2") })
+
+	assert.NoError(t, rh.handleEvent("log", handlers["log"], nil, mockKong(t, "req-1")))
+	assert.Equal(t, 42, logged)
+	assert.Len(t, requestStores.stores, 1)
+	assert.Empty(t, requestStores.events)
+}
+
+type slowStoreConfig struct {
+	release chan struct{}
+}
+
+func (c slowStoreConfig) Log(kong *pdk.PDK) error {
+	if _, err := GetRequestStore(kong); err != nil {
+		return err
+	}
+	<-c.release
+	return nil
+}
+
+func TestRequestStoreTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	rh := newRpcHandler(func() interface{} { return &slowStoreConfig{release: release} }, "0.1", 1,
+		WithPhaseTimeout(5*time.Millisecond))
+
+	var status InstanceStatus
+	assert.NoError(t, rh.StartInstance(PluginConfig{Name: "test", Config: []byte(`{"__seq__":1}`)}, &status))
+	handlers := rh.instances[status.Id].handlers
+
+	err := rh.handleEvent("log", handlers["log"], nil, mockKong(t, "req-slow"))
+	assert.EqualError(t, err, "log handler timed out after 5ms")
+
+	requestStores.lock.Lock()
+	defer requestStores.lock.Unlock()
+	assert.Empty(t, requestStores.stores)
+	assert.Empty(t, requestStores.events)
+}
+
+func TestExpireEvents(t *testing.T) {
+	r := storeRegistry{stores: map[string]*RequestStore{}, events: map[*pdk.PDK]string{}}
+	r.get(&pdk.PDK{}, "old").created = time.Now().Add(-2 * requestStoreTimeout)
+	r.get(&pdk.PDK{}, "new")
+
+	r.lastExpire = time.Time{}
+	r.expire()
+	assert.Len(t, r.stores, 1)
+	assert.Len(t, r.events, 1)
+	for _, id := range r.events {
+		assert.Equal(t, "new", id)
+	}
+}
+
+type accessStoreConfig struct{}
+
+func (c accessStoreConfig) Access(kong *pdk.PDK) error {
+	store, err := GetRequestStore(kong)
+	if err != nil {
+		return err
+	}
+	store.Set("seen", true)
+	return nil
+}
+
+func TestRequestStoreWithoutLog(t *testing.T) {
+	rh := newRpcHandler(func() interface{} { return &accessStoreConfig{} }, "0.1", 1)
+
+	var status InstanceStatus
+	assert.NoError(t, rh.StartInstance(PluginConfig{Name: "test", Config: []byte(`{"__seq__":1}`)}, &status))
+	handlers := rh.instances[status.Id].handlers
+
+	// access is the last phase it handles, so nothing is kept after it
+	assert.NoError(t, rh.handleEvent("access", handlers["access"], nil, mockKong(t, "req-access")))
+	assert.Empty(t, requestStores.stores)
+	assert.Empty(t, requestStores.events)
+}
+
+func TestLastPhases(t *testing.T) {
+	assert.Equal(t, map[string]bool{"log": true}, getLastPhases([]string{"access", "log"}))
+	assert.Equal(t, map[string]bool{"access": true, "preread": true}, getLastPhases([]string{"rewrite", "access", "preread"}))
+	assert.Equal(t, map[string]bool{}, getLastPhases([]string{"certificate"}))
+}
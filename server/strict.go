@@ -0,0 +1,29 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// decodeStrict decodes the configuration data into v like json.Unmarshal,
+// but fails on keys that match no field, such as those of fields removed
+// from the config type.  The metadata Kong adds (see reservedKeys) is left
+// out.  Fields of types with their own UnmarshalJSON method aren't checked.
+func decodeStrict(data []byte, v interface{}) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	for _, key := range reservedKeys {
+		delete(fields, key)
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStrictDecoding(t *testing.T) {
+	type Limits struct {
+		Second int `json:"second"`
+	}
+	type Config struct {
+		Name   string `json:"name"`
+		Limits Limits `json:"limits"`
+	}
+	constructor := func() interface{} { return &Config{} }
+
+	var status InstanceStatus
+	rh := newRpcHandler(constructor, "0.1", 1)
+	assert.NoError(t, rh.StartInstance(PluginConfig{Name: "test", Config: []byte(`{"__seq__":1,"name":"a","minute":5}`)}, &status))
+
+	rh = newRpcHandler(constructor, "0.1", 1, WithStrictDecoding())
+	assert.NoError(t, rh.StartInstance(PluginConfig{Name: "test", Config: []byte(`{"__seq__":1,"__key__":"k","name":"a"}`)}, &status))
+	assert.Equal(t, "a", status.Config.(*Config).Name)
+
+	err := rh.StartInstance(PluginConfig{Name: "test", Config: []byte(`{"__seq__":2,"name":"a","minute":5}`)}, &status)
+	assert.EqualError(t, err, `decoding config: json: unknown field "minute"`)
+
+	err = rh.StartInstance(PluginConfig{Name: "test", Config: []byte(`{"__seq__":3,"limits":{"second":1,"hour":2}}`)}, &status)
+	assert.EqualError(t, err, `decoding config: json: unknown field "hour"`)
+}
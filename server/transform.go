@@ -0,0 +1,111 @@
+package server
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Normalizations a string field can get with the `transform` tag, as in
+// `kong:"transform=lower"`.  Several are applied in order when separated
+// by ';', as in `kong:"transform=trim;lower"`.  They only run in the plugin,
+// on the decoded config: Kong's schema transformations are Lua functions,
+// which can't be sent to it, so Kong stores and shows the values as given.
+var transforms = map[string]func(string) string{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"trim":  strings.TrimSpace,
+}
+
+// fieldTransforms returns the transformations given by the `transform` tag
+// of a field, leaving out unknown ones.
+func fieldTransforms(field reflect.StructField) (names []string, unknown []string) {
+	value, ok := kongTagValue(field, "transform")
+	if !ok {
+		return nil, nil
+	}
+
+	for _, name := range strings.Split(value, ";") {
+		if _, ok := transforms[name]; ok {
+			names = append(names, name)
+		} else {
+			unknown = append(unknown, name)
+		}
+	}
+	return names, unknown
+}
+
+// isStringy tells whether t holds strings a transformation applies to:
+// it's a string, or a slice of strings, possibly through pointers.
+func isStringy(t reflect.Type) bool {
+	t = derefType(t)
+	if t.Kind() == reflect.Slice {
+		t = derefType(t.Elem())
+	}
+	return t.Kind() == reflect.String
+}
+
+// checkTransforms reports the problems with the `transform` tag of a field.
+func (b *schemaBuilder) checkTransforms(field reflect.StructField, path string) {
+	names, unknown := fieldTransforms(field)
+	for _, name := range unknown {
+		b.warnf("field %s: unknown transform %q", path, name)
+	}
+	if len(names) > 0 && !isStringy(field.Type) {
+		b.warnf("field %s: transform is only for strings", path)
+	}
+}
+
+// applyTransforms normalizes the string fields of a decoded config as
+// their `transform` tags say, including those of records in arrays and maps.
+func applyTransforms(v reflect.Value) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if forEachElem(v, applyTransforms) {
+		return
+	}
+	if v.Kind() != reflect.Struct {
+		return
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if len(field.PkgPath) != 0 {
+			continue
+		}
+
+		value := v.Field(i)
+		if !isStringy(field.Type) {
+			applyTransforms(value)
+			continue
+		}
+
+		names, _ := fieldTransforms(field)
+		for _, name := range names {
+			transformValue(value, transforms[name])
+		}
+	}
+}
+
+// transformValue applies f to the strings held by v.
+func transformValue(v reflect.Value, f func(string) string) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(f(v.String()))
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			transformValue(v.Index(i), f)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransformations(t *testing.T) {
+	type Config struct {
+		Header  string   `json:"header" kong:"transform=lower"`
+		Names   []string `json:"names" kong:"transform=trim;upper"`
+		Plain   string   `json:"plain"`
+		Count   int      `json:"count" kong:"transform=lower"`
+		Unknown string   `json:"unknown" kong:"transform=title"`
+	}
+
+	b := &schemaBuilder{}
+	b.getSchemaDict(reflect.TypeOf(Config{}), "config")
+	assert.Equal(t, []string{
+		"field config.count: transform is only for strings",
+		`field config.unknown: unknown transform "title"`,
+	}, b.warnings)
+
+	rh := newRpcHandler(func() interface{} { return &Config{} }, "0.1", 1)
+	schema, err := rh.getSchema("test")
+	assert.NoError(t, err)
+	// Kong can't run them
+	assert.NotContains(t, schema, "transformations")
+
+	var status InstanceStatus
+	assert.NoError(t, rh.StartInstance(PluginConfig{Name: "test", Config: []byte(`{"header":"X-Request-ID","names":[" a ","b"],"plain":"Mixed"}`)}, &status))
+	config := status.Config.(*Config)
+	assert.Equal(t, "x-request-id", config.Header)
+	assert.Equal(t, []string{"A", "B"}, config.Names)
+	assert.Equal(t, "Mixed", config.Plain)
+}
+
+func TestTransformationsInRecords(t *testing.T) {
+	type Header struct {
+		Name string `json:"name" kong:"transform=lower"`
+	}
+	type Config struct {
+		Headers []Header           `json:"headers"`
+		ByRoute map[string]*Header `json:"by_route"`
+	}
+
+	rh := newRpcHandler(func() interface{} { return &Config{} }, "0.1", 1)
+	var status InstanceStatus
+	assert.NoError(t, rh.StartInstance(PluginConfig{Name: "test",
+		Config: []byte(`{"headers":[{"name":"ABC"}],"by_route":{"a":{"name":"X-Id"}}}`)}, &status))
+	config := status.Config.(*Config)
+	assert.Equal(t, []Header{{"abc"}}, config.Headers)
+	assert.Equal(t, "x-id", config.ByRoute["a"].Name)
+}
@@ -0,0 +1,211 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// A ValidationResult is a problem found by ValidateConfig in a field of the
+// configuration data.
+type ValidationResult struct {
+	Path      string      // dot-separated field names, with [i] for array elements
+	Validator string      // schema attribute the value fails: required, type, one_of, between, len_min or len_max
+	Message   string      // readable description of the problem
+	Value     interface{} // the offending value, as decoded from JSON
+}
+
+// ValidateConfig checks configuration data (a JSON object, as sent by Kong)
+// against the schema of the plugin StartServer would serve given the same
+// constructor and options, much like Kong would before sending it.  Match
+// patterns are not checked.  Returns an error if the data can't be decoded
+// at all.
+func ValidateConfig(constructor func() interface{}, config []byte, opts ...ServerOption) ([]ValidationResult, error) {
+	rh, err := buildRpcHandler(constructor, "", 0, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(config, &data); err != nil {
+		return nil, fmt.Errorf("decoding config: %w", err)
+	}
+
+	b := rh.newSchemaBuilder()
+	decl := b.getSchemaDict(rh.configType, "config")
+	v := validator{results: []ValidationResult{}}
+	v.value(decl, data, "")
+	return v.results, nil
+}
+
+type validator struct {
+	results []ValidationResult
+}
+
+func (v *validator) fail(path, validator string, value interface{}, format string, args ...interface{}) {
+	v.results = append(v.results, ValidationResult{
+		Path:      path,
+		Validator: validator,
+		Message:   fmt.Sprintf(format, args...),
+		Value:     value,
+	})
+}
+
+// value checks a decoded JSON value against its declaration.
+func (v *validator) value(decl schemaDict, value interface{}, path string) {
+	if !v.hasType(decl, value, path) {
+		return
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		switch decl["type"] {
+		case "record":
+			v.record(decl, value, path)
+		case "map":
+			values, _ := decl["values"].(schemaDict)
+			for _, key := range sortedKeys(value) {
+				v.value(values, value[key], join(path, key))
+			}
+		}
+
+	case []interface{}:
+		v.length(decl, value, len(value), path)
+		elements, _ := decl["elements"].(schemaDict)
+		for i, element := range value {
+			v.value(elements, element, path+"["+strconv.Itoa(i)+"]")
+		}
+
+	case string:
+		v.oneOf(decl, value, slices.Contains(toStrings(decl["one_of"]), value), path)
+		v.length(decl, value, len(value), path)
+
+	case float64:
+		if values, ok := toNumbers(decl["one_of"]); ok {
+			v.oneOf(decl, value, slices.Contains(values, value), path)
+		}
+		if bounds, ok := toNumbers(decl["between"]); ok && len(bounds) == 2 {
+			if value < bounds[0] || value > bounds[1] {
+				v.fail(path, "between", value, "value should be between %v and %v", bounds[0], bounds[1])
+			}
+		}
+	}
+}
+
+// record checks the fields of a record, skipping Kong's metadata.
+func (v *validator) record(decl schemaDict, value map[string]interface{}, path string) {
+	fields, _ := decl["fields"].([]schemaDict)
+	for _, field := range fields {
+		for name, fieldDecl := range field {
+			fieldDecl, _ := fieldDecl.(schemaDict)
+			fieldPath := join(path, name)
+			fieldValue, ok := value[name]
+			if !ok || fieldValue == nil {
+				_, hasDefault := fieldDecl["default"]
+				if fieldDecl["required"] == true && !hasDefault {
+					v.fail(fieldPath, "required", nil, "required field missing")
+				}
+				continue
+			}
+			v.value(fieldDecl, fieldValue, fieldPath)
+		}
+	}
+}
+
+// jsonTypes are the Go types encoding/json decodes each Kong type into.
+var jsonTypes = map[string]string{
+	"string":  "string",
+	"boolean": "bool",
+	"integer": "float64",
+	"number":  "float64",
+	"array":   "[]interface {}",
+	"record":  "map[string]interface {}",
+	"map":     "map[string]interface {}",
+}
+
+func (v *validator) hasType(decl schemaDict, value interface{}, path string) bool {
+	kongType, _ := decl["type"].(string)
+	goType, ok := jsonTypes[kongType]
+	if !ok || value == nil {
+		return true
+	}
+
+	if fmt.Sprintf("%T", value) != goType {
+		v.fail(path, "type", value, "expected a value of type %s", kongType)
+		return false
+	}
+	if n, ok := value.(float64); ok && kongType == "integer" && n != float64(int64(n)) {
+		v.fail(path, "type", value, "expected a value of type integer")
+		return false
+	}
+
+	return true
+}
+
+func (v *validator) oneOf(decl schemaDict, value interface{}, found bool, path string) {
+	if _, ok := decl["one_of"]; !ok || found {
+		return
+	}
+
+	v.fail(path, "one_of", value, "expected one of: %s", joinValues(decl["one_of"]))
+}
+
+// length checks the len_min and len_max of strings (in bytes) and arrays.
+func (v *validator) length(decl schemaDict, value interface{}, n int, path string) {
+	if lenMin, ok := decl["len_min"].(int); ok && n < lenMin {
+		v.fail(path, "len_min", value, "length must be at least %d", lenMin)
+	}
+	if lenMax, ok := decl["len_max"].(int); ok && n > lenMax {
+		v.fail(path, "len_max", value, "length must be at most %d", lenMax)
+	}
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func toStrings(list interface{}) []string {
+	values, _ := list.([]string)
+	return values
+}
+
+// toNumbers converts a list of integers or numbers from a declaration to
+// the float64 values encoding/json decodes.
+func toNumbers(list interface{}) ([]float64, bool) {
+	switch list := list.(type) {
+	case []int:
+		values := make([]float64, len(list))
+		for i, n := range list {
+			values[i] = float64(n)
+		}
+		return values, true
+	case []float64:
+		return list, true
+	}
+
+	return nil, false
+}
+
+func joinValues(list interface{}) string {
+	items := []string{}
+	l := reflect.ValueOf(list)
+	for i := 0; i < l.Len(); i++ {
+		items = append(items, fmt.Sprint(l.Index(i).Interface()))
+	}
+	return strings.Join(items, ", ")
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateConfig(t *testing.T) {
+	type Config struct {
+		Port   int      `json:"port" kong:"between=1;65535"`
+		Mode   string   `json:"mode" kong:"one_of=fast;safe"`
+		Name   string   `json:"name" kong:"required=true"`
+		Codes  []int    `json:"codes" kong:"one_of=200;404"`
+		Weight float64  `json:"weight"`
+		Hosts  []string `json:"hosts" kong:"len_max=1"`
+	}
+	constructor := func() interface{} { return &Config{} }
+
+	results, err := ValidateConfig(constructor, []byte(`{"port":80,"mode":"safe","name":"x","codes":[200],"__seq__":1}`))
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+
+	results, err = ValidateConfig(constructor,
+		[]byte(`{"port":70000,"mode":"slow","codes":[200,500],"weight":"heavy","hosts":["a","b"]}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []ValidationResult{
+		{Path: "port", Validator: "between", Message: "value should be between 1 and 65535", Value: 70000.0},
+		{Path: "mode", Validator: "one_of", Message: "expected one of: fast, safe", Value: "slow"},
+		{Path: "name", Validator: "required", Message: "required field missing"},
+		{Path: "codes[1]", Validator: "one_of", Message: "expected one of: 200, 404", Value: 500.0},
+		{Path: "weight", Validator: "type", Message: "expected a value of type number", Value: "heavy"},
+		{Path: "hosts", Validator: "len_max", Message: "length must be at most 1", Value: []interface{}{"a", "b"}},
+	}, results)
+
+	_, err = ValidateConfig(constructor, []byte(`{`))
+	assert.EqualError(t, err, "decoding config: unexpected end of JSON input")
+}
@@ -240,6 +240,13 @@ func (e *TestEnv) noErr(err error) {
 	}
 }
 
+// handlerErr logs an error returned by a handler, as the plugin server does.
+func (e *TestEnv) handlerErr(phase string, err error) {
+	if err != nil {
+		e.t.Logf("error in %s handler: %s", phase, err)
+	}
+}
+
 // Internal use.  Calls the Errof function with the test context.
 func (e *TestEnv) Errorf(format string, args ...interface{}) {
 	e.t.Errorf(format, args...)
@@ -602,6 +609,9 @@ func (e *TestEnv) DoCertificate(config interface{}) {
 	if h, ok := config.(interface{ Certificate(*pdk.PDK) }); ok {
 		e.t.Log("Certificate")
 		h.Certificate(e.pdk)
+	} else if h, ok := config.(interface{ Certificate(*pdk.PDK) error }); ok {
+		e.t.Log("Certificate")
+		e.handlerErr("Certificate", h.Certificate(e.pdk))
 	}
 }
 
@@ -615,6 +625,9 @@ func (e *TestEnv) DoRewrite(config interface{}) {
 	if h, ok := config.(interface{ Rewrite(*pdk.PDK) }); ok {
 		e.t.Log("Rewrite")
 		h.Rewrite(e.pdk)
+	} else if h, ok := config.(interface{ Rewrite(*pdk.PDK) error }); ok {
+		e.t.Log("Rewrite")
+		e.handlerErr("Rewrite", h.Rewrite(e.pdk))
 	}
 }
 
@@ -628,6 +641,9 @@ func (e *TestEnv) DoAccess(config interface{}) {
 	if h, ok := config.(interface{ Access(*pdk.PDK) }); ok {
 		e.t.Log("Access")
 		h.Access(e.pdk)
+	} else if h, ok := config.(interface{ Access(*pdk.PDK) error }); ok {
+		e.t.Log("Access")
+		e.handlerErr("Access", h.Access(e.pdk))
 	}
 }
 
@@ -644,6 +660,9 @@ func (e *TestEnv) DoResponse(config interface{}) {
 	if h, ok := config.(interface{ Response(*pdk.PDK) }); ok {
 		e.t.Log("Response")
 		h.Response(e.pdk)
+	} else if h, ok := config.(interface{ Response(*pdk.PDK) error }); ok {
+		e.t.Log("Response")
+		e.handlerErr("Response", h.Response(e.pdk))
 	}
 }
 
@@ -655,6 +674,9 @@ func (e *TestEnv) DoPreread(config interface{}) {
 	if h, ok := config.(interface{ Preread(*pdk.PDK) }); ok {
 		e.t.Log("Preread")
 		h.Preread(e.pdk)
+	} else if h, ok := config.(interface{ Preread(*pdk.PDK) error }); ok {
+		e.t.Log("Preread")
+		e.handlerErr("Preread", h.Preread(e.pdk))
 	}
 }
 
@@ -667,6 +689,9 @@ func (e *TestEnv) DoLog(config interface{}) {
 	if h, ok := config.(interface{ Log(*pdk.PDK) }); ok {
 		e.t.Log("Log")
 		h.Log(e.pdk)
+	} else if h, ok := config.(interface{ Log(*pdk.PDK) error }); ok {
+		e.t.Log("Log")
+		e.handlerErr("Log", h.Log(e.pdk))
 	}
 }
 